@@ -3,10 +3,12 @@ package main
 import (
 	"flag"
 	"runtime"
+	"time"
 
 	"github.com/GreatestGuys/pifuxelck-server-go/server"
 	"github.com/GreatestGuys/pifuxelck-server-go/server/db"
 	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/models"
 )
 
 var port = flag.Int("port", 3000, "The port number to listen on.")
@@ -29,6 +31,36 @@ var mysqlUser = flag.String("mysql-user", "",
 var mysqlPassword = flag.String("mysql-password", "",
 	"The password to use when connecting to the pifuxelck MySQL server.")
 
+var passwordMinLength = flag.Int("password-min-length", 8,
+	"The minimum number of characters in a password.")
+
+var passwordMaxLength = flag.Int("password-max-length", 72,
+	"The maximum number of bytes in a password, at most 72.")
+
+var breachedPasswords = flag.String("breached-passwords", "",
+	"A directory of SHA-1 range files of breached passwords that may not be used.")
+
+var minTurnDuration = flag.Duration("min-turn-duration", 5*time.Minute,
+	"The shortest turn duration that can be requested for a game.")
+
+var maxTurnDuration = flag.Duration("max-turn-duration", 7*24*time.Hour,
+	"The longest turn duration that can be requested for a game.")
+
+var defaultTurnDuration = flag.Duration("default-turn-duration", 2*24*time.Hour,
+	"The turn duration of games that do not request one.")
+
+var dailySkipAllowance = flag.Int("daily-skips", 3,
+	"The number of turns a player may skip per day, 0 disables skipping.")
+
+var votingWindow = flag.Duration("voting-window", 3*24*time.Hour,
+	"How long after a game completes its players may vote on its turns.")
+
+var seasonStart = flag.String("season-start", "2017-01-01",
+	"The date, as YYYY-MM-DD in UTC, on which the first leaderboard season begins.")
+
+var seasonLength = flag.Duration("season-length", 91*24*time.Hour,
+	"The length of each leaderboard season.")
+
 func main() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
@@ -37,6 +69,11 @@ func main() {
 	log.Init()
 	log.SetLogLevel(*logLevel)
 
+	firstSeason, err := time.Parse("2006-01-02", *seasonStart)
+	if err != nil {
+		log.Fatalf("Invalid season start %#v, %v.", *seasonStart, err)
+	}
+
 	server.Run(server.Config{
 		Port: *port,
 		DBConfig: db.Config{
@@ -46,5 +83,19 @@ func main() {
 			User:     *mysqlUser,
 			Password: *mysqlPassword,
 		},
+		ModelConfig: models.Config{
+			PasswordPolicy: models.PasswordPolicy{
+				MinLength:            *passwordMinLength,
+				MaxLength:            *passwordMaxLength,
+				BreachedPasswordsDir: *breachedPasswords,
+			},
+			MinTurnDuration:     *minTurnDuration,
+			MaxTurnDuration:     *maxTurnDuration,
+			DefaultTurnDuration: *defaultTurnDuration,
+			DailySkipAllowance:  *dailySkipAllowance,
+			VotingWindow:        *votingWindow,
+			SeasonStart:         firstSeason,
+			SeasonLength:        *seasonLength,
+		},
 	})
 }
@@ -15,6 +15,9 @@ func InstallAccountHandlers(r *mux.Router) {
 	common.InstallHandler(r, "/account/login", accountLogin).Methods("POST")
 	common.InstallHandler(r, "/account/register", accountRegister).Methods("POST")
 	common.InstallHandler(r, "/account", accountUpdate).Methods("PUT")
+	common.InstallHandler(r, "/account/settings", accountSettings).Methods("GET")
+	common.InstallHandler(r, "/account/settings", accountUpdateSettings).
+		Methods("PUT")
 }
 
 func accountLogin(w http.ResponseWriter, r *http.Request) {
@@ -81,18 +84,62 @@ var accountUpdate = common.AuthHandlerFunc(func(id int64, w http.ResponseWriter,
 		return
 	}
 
-	log.Debugf("Attempting to update password for %#v.", user.DisplayName)
+	if user.DisplayName == "" && user.Password == "" {
+		common.RespondClientError(w, &models.Errors{
+			App: []string{"Either a display name or a password is required."},
+		})
+		return
+	}
 
 	// Override any ID given in the JSON request body with the actual
 	// authenticated user ID.
 	user.ID = id
-	user, userErr := models.UserSetPassword(*user)
+
+	log.Debugf("Attempting to update account of %v.", id)
+	user, userErr := models.UserUpdate(*user)
 	if userErr != nil {
-		log.Debugf("Failed to update password, %v.", userErr.Error())
+		log.Debugf("Failed to update account, %v.", userErr.Error())
 		common.RespondClientError(w, &models.Errors{User: userErr})
 		return
 	}
 
-	log.Infof("Successfully updated password of %#v.", user.DisplayName)
+	log.Infof("Successfully updated account of %#v.", user.DisplayName)
 	common.RespondSuccess(w, &models.Message{User: user})
 })
+
+var accountSettings = common.AuthHandlerFunc(func(id int64, w http.ResponseWriter, r *http.Request) {
+	log.Debugf("User %v is requesting their settings.", id)
+	settings, errors := models.GetSettings(id)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v retrieved their settings.", id)
+	common.RespondSuccess(w, &models.Message{Settings: settings})
+})
+
+var accountUpdateSettings = common.AuthHandlerFunc(func(id int64, w http.ResponseWriter, r *http.Request) {
+	settings, err := common.RequestSettingsMessage(r)
+	if err != nil {
+		common.RespondClientError(w, err)
+		return
+	}
+
+	log.Debugf("User %v is updating their settings.", id)
+	errors := models.UpdateSettings(id, *settings)
+	if errors != nil {
+		log.Debugf("Failed to update settings of %v.", id)
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	settings, errors = models.GetSettings(id)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v updated their settings.", id)
+	common.RespondSuccess(w, &models.Message{Settings: settings})
+})
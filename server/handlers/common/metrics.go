@@ -62,7 +62,7 @@ func InstallHandler(r *mux.Router, path string, f http.HandlerFunc) *mux.Route {
 
 	addCorsHeaders := func(w http.ResponseWriter) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "x-pifuxelck-auth")
 	}
 
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 
 	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
 	"github.com/GreatestGuys/pifuxelck-server-go/server/models"
@@ -74,3 +75,116 @@ func RequestTurnMessage(r *http.Request) (*models.Turn, *models.Errors) {
 
 	return msg.Turn, nil
 }
+
+// RequestContactGroupMessage extracts and returns a ContactGroup model from the
+// request body and returns an error if unable to do so.
+func RequestContactGroupMessage(r *http.Request) (*models.ContactGroup, *models.Errors) {
+	msg, err := RequestMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.ContactGroup == nil {
+		return nil, &models.Errors{
+			App: []string{"No contact_group object in request body."}}
+	}
+
+	return msg.ContactGroup, nil
+}
+
+// RequestSettingsMessage extracts and returns a Settings model from the request
+// body and returns an error if unable to do so.
+func RequestSettingsMessage(r *http.Request) (*models.Settings, *models.Errors) {
+	msg, err := RequestMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.Settings == nil {
+		return nil, &models.Errors{
+			App: []string{"No settings object in request body."}}
+	}
+
+	return msg.Settings, nil
+}
+
+// RequestDisplayNamesMessage extracts and returns a list of display names from
+// the request body and returns an error if unable to do so.
+func RequestDisplayNamesMessage(r *http.Request) ([]string, *models.Errors) {
+	msg, err := RequestMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.DisplayNames == nil {
+		return nil, &models.Errors{
+			App: []string{"No display_names array in request body."}}
+	}
+
+	return msg.DisplayNames, nil
+}
+
+// RequestCommentMessage extracts and returns a Comment model from the request
+// body and returns an error if unable to do so.
+func RequestCommentMessage(r *http.Request) (*models.Comment, *models.Errors) {
+	msg, err := RequestMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.Comment == nil {
+		return nil, &models.Errors{
+			App: []string{"No comment object in request body."}}
+	}
+
+	return msg.Comment, nil
+}
+
+// RequestRematchMessage extracts and returns a Rematch model from the request
+// body and returns an error if unable to do so.
+func RequestRematchMessage(r *http.Request) (*models.Rematch, *models.Errors) {
+	msg, err := RequestMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.Rematch == nil {
+		return nil, &models.Errors{
+			App: []string{"No rematch object in request body."}}
+	}
+
+	return msg.Rematch, nil
+}
+
+// RequestScheduleMessage extracts and returns a Schedule model from the request
+// body and returns an error if unable to do so.
+func RequestScheduleMessage(r *http.Request) (*models.Schedule, *models.Errors) {
+	msg, err := RequestMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.Schedule == nil {
+		return nil, &models.Errors{
+			App: []string{"No schedule object in request body."}}
+	}
+
+	return msg.Schedule, nil
+}
+
+// RequestQueryInt returns the non-negative integer query parameter of the given
+// name, or def if the parameter is missing or empty, and returns an error if
+// the parameter is not a non-negative integer.
+func RequestQueryInt(r *http.Request, name string, def int64) (int64, *models.Errors) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || i < 0 {
+		log.Warnf("Invalid %v query parameter %#v.", name, value)
+		return 0, &models.Errors{App: []string{"Invalid " + name + "."}}
+	}
+	return i, nil
+}
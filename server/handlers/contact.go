@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/GreatestGuys/pifuxelck-server-go/server/handlers/common"
 	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
@@ -12,13 +13,56 @@ import (
 // InstallContactHandlers takes a gorilla router and installs /contact/*
 // endpoints on it.
 func InstallContactHandlers(r *mux.Router) {
+	common.InstallHandler(r, "/contacts", contactList).Methods("GET")
+	common.InstallHandler(r, "/contacts/{id:[0-9]+}", contactAdd).Methods("PUT")
+	common.InstallHandler(r, "/contacts/{id:[0-9]+}", contactRemove).
+		Methods("DELETE")
+	common.InstallHandler(r, "/contacts/lookup", contactLookupBatch).
+		Methods("POST")
 	common.InstallHandler(r, "/contacts/lookup/{displayName}", contactLookup).
 		Methods("GET")
+	common.InstallHandler(r, "/contacts/search", contactSearch).Methods("GET")
+
+	common.InstallHandler(r, "/contacts/blocked", contactBlockedList).
+		Methods("GET")
+	common.InstallHandler(r, "/contacts/blocked/{id:[0-9]+}", contactBlock).
+		Methods("PUT")
+	common.InstallHandler(r, "/contacts/blocked/{id:[0-9]+}", contactUnblock).
+		Methods("DELETE")
+
+	common.InstallHandler(r, "/contacts/friends", contactFriendList).
+		Methods("GET")
+	common.InstallHandler(r, "/contacts/friends/{id:[0-9]+}", contactUnfriend).
+		Methods("DELETE")
+	common.InstallHandler(r, "/contacts/requests", contactRequestList).
+		Methods("GET")
+	common.InstallHandler(r, "/contacts/requests/{id:[0-9]+}", contactRequestSend).
+		Methods("PUT")
+	common.InstallHandler(r, "/contacts/requests/{id:[0-9]+}", contactRequestCancel).
+		Methods("DELETE")
+	common.InstallHandler(r, "/contacts/requests/{id:[0-9]+}/accept",
+		contactRequestAccept).Methods("POST")
+	common.InstallHandler(r, "/contacts/requests/{id:[0-9]+}/decline",
+		contactRequestDecline).Methods("POST")
+
+	common.InstallHandler(r, "/contacts/groups", contactGroupList).Methods("GET")
+	common.InstallHandler(r, "/contacts/groups", contactGroupCreate).
+		Methods("POST")
+	common.InstallHandler(r, "/contacts/groups/{id:[0-9]+}", contactGroupRename).
+		Methods("PUT")
+	common.InstallHandler(r, "/contacts/groups/{id:[0-9]+}", contactGroupDelete).
+		Methods("DELETE")
+	common.InstallHandler(r,
+		"/contacts/groups/{id:[0-9]+}/members/{memberID:[0-9]+}",
+		contactGroupAddMember).Methods("PUT")
+	common.InstallHandler(r,
+		"/contacts/groups/{id:[0-9]+}/members/{memberID:[0-9]+}",
+		contactGroupRemoveMember).Methods("DELETE")
 }
 
-var contactLookup = common.AuthHandlerFunc(func(_ int64, w http.ResponseWriter, r *http.Request) {
+var contactLookup = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
 	displayName := mux.Vars(r)["displayName"]
-	user, userErr := models.ContactLookup(displayName)
+	user, userErr := models.ContactLookup(userID, displayName)
 
 	if userErr != nil {
 		log.Debugf("Lookup for contact %#v failed.", displayName)
@@ -29,3 +73,333 @@ var contactLookup = common.AuthHandlerFunc(func(_ int64, w http.ResponseWriter,
 	log.Infof("Successful lookup of contact %#v.", displayName)
 	common.RespondSuccess(w, &models.Message{User: user})
 })
+
+var contactLookupBatch = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	names, err := common.RequestDisplayNamesMessage(r)
+	if err != nil {
+		common.RespondClientError(w, err)
+		return
+	}
+
+	log.Debugf("User %v is looking up %v contacts.", userID, len(names))
+	users, userErrs, errors := models.ContactLookupBatch(userID, names)
+	if errors != nil {
+		log.Debugf("Batch lookup of contacts failed.")
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	// Names that could not be resolved do not fail the request, instead their
+	// errors are returned alongside the users that were found.
+	msg := &models.Message{Users: users}
+	if len(userErrs) > 0 {
+		msg.Errors = &models.Errors{Lookup: userErrs}
+	}
+
+	log.Infof("User %v looked up %v of %v contacts.", userID, len(users), len(names))
+	common.RespondSuccess(w, msg)
+})
+
+var contactSearch = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	cursor := r.URL.Query().Get("cursor")
+
+	log.Debugf("User %v is searching for %#v at %#v.", userID, query, cursor)
+	users, next, errors := models.SearchUsers(userID, query, cursor)
+	if errors != nil {
+		log.Debugf("Search for %#v failed.", query)
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v searched for %#v.", userID, query)
+	common.RespondSuccess(w, &models.Message{
+		Users: users,
+		Meta:  &models.Meta{NextCursor: next},
+	})
+})
+
+// respondContacts responds with the complete contact list of the given user.
+// Every contact endpoint responds with the full list so that all of a player's
+// devices converge on the list stored by the server.
+func respondContacts(userID int64, w http.ResponseWriter) {
+	users, errors := models.Contacts(userID)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	common.RespondSuccess(w, &models.Message{Users: users})
+}
+
+var contactList = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	log.Debugf("User %v is requesting their contacts.", userID)
+	respondContacts(userID, w)
+	log.Infof("User %v retrieved their contacts.", userID)
+})
+
+var contactAdd = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	contactID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	log.Debugf("User %v is adding contact %v.", userID, contactID)
+	errors := models.AddContact(userID, contactID)
+	if errors != nil {
+		log.Debugf("Failed to add contact %v.", contactID)
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v added contact %v.", userID, contactID)
+	respondContacts(userID, w)
+})
+
+var contactRemove = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	contactID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	log.Debugf("User %v is removing contact %v.", userID, contactID)
+	errors := models.RemoveContact(userID, contactID)
+	if errors != nil {
+		log.Debugf("Failed to remove contact %v.", contactID)
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v removed contact %v.", userID, contactID)
+	respondContacts(userID, w)
+})
+
+var contactGroupList = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	log.Debugf("User %v is requesting their contact groups.", userID)
+	groups, errors := models.ContactGroups(userID)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v retrieved their contact groups.", userID)
+	common.RespondSuccess(w, &models.Message{ContactGroups: groups})
+})
+
+var contactGroupCreate = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	group, err := common.RequestContactGroupMessage(r)
+	if err != nil {
+		common.RespondClientError(w, err)
+		return
+	}
+
+	log.Debugf("User %v is creating contact group %#v.", userID, group.Name)
+	group, errors := models.CreateContactGroup(userID, *group)
+	if errors != nil {
+		log.Debugf("Failed to create contact group.")
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v created contact group %v.", userID, group.ID)
+	common.RespondSuccess(w, &models.Message{ContactGroup: group})
+})
+
+// respondContactGroup responds with the current state of a single contact
+// group.
+func respondContactGroup(userID, groupID int64, w http.ResponseWriter) {
+	group, errors := models.ContactGroupByID(userID, groupID)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	common.RespondSuccess(w, &models.Message{ContactGroup: group})
+}
+
+var contactGroupRename = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	group, err := common.RequestContactGroupMessage(r)
+	if err != nil {
+		common.RespondClientError(w, err)
+		return
+	}
+
+	groupID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	log.Debugf("User %v is renaming contact group %v.", userID, groupID)
+	errors := models.RenameContactGroup(userID, groupID, group.Name)
+	if errors != nil {
+		log.Debugf("Failed to rename contact group %v.", groupID)
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v renamed contact group %v.", userID, groupID)
+	respondContactGroup(userID, groupID, w)
+})
+
+var contactGroupDelete = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	groupID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	log.Debugf("User %v is deleting contact group %v.", userID, groupID)
+	errors := models.DeleteContactGroup(userID, groupID)
+	if errors != nil {
+		log.Debugf("Failed to delete contact group %v.", groupID)
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v deleted contact group %v.", userID, groupID)
+	common.RespondSuccessNoContent(w)
+})
+
+var contactGroupAddMember = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	groupID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	memberID, _ := strconv.ParseInt(mux.Vars(r)["memberID"], 10, 64)
+
+	log.Debugf("User %v is adding %v to contact group %v.", userID, memberID, groupID)
+	errors := models.AddContactGroupMember(userID, groupID, memberID)
+	if errors != nil {
+		log.Debugf("Failed to add member to contact group %v.", groupID)
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v added %v to contact group %v.", userID, memberID, groupID)
+	respondContactGroup(userID, groupID, w)
+})
+
+var contactGroupRemoveMember = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	groupID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	memberID, _ := strconv.ParseInt(mux.Vars(r)["memberID"], 10, 64)
+
+	log.Debugf("User %v is removing %v from contact group %v.", userID, memberID, groupID)
+	errors := models.RemoveContactGroupMember(userID, groupID, memberID)
+	if errors != nil {
+		log.Debugf("Failed to remove member from contact group %v.", groupID)
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v removed %v from contact group %v.", userID, memberID, groupID)
+	respondContactGroup(userID, groupID, w)
+})
+
+// respondBlockedUsers responds with the complete block list of the given user.
+func respondBlockedUsers(userID int64, w http.ResponseWriter) {
+	users, errors := models.BlockedUsers(userID)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	common.RespondSuccess(w, &models.Message{Users: users})
+}
+
+var contactBlockedList = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	log.Debugf("User %v is requesting their blocked players.", userID)
+	respondBlockedUsers(userID, w)
+	log.Infof("User %v retrieved their blocked players.", userID)
+})
+
+var contactBlock = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	blockedID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	log.Debugf("User %v is blocking %v.", userID, blockedID)
+	errors := models.BlockUser(userID, blockedID)
+	if errors != nil {
+		log.Debugf("Failed to block %v.", blockedID)
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v blocked %v.", userID, blockedID)
+	respondBlockedUsers(userID, w)
+})
+
+var contactUnblock = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	blockedID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	log.Debugf("User %v is unblocking %v.", userID, blockedID)
+	errors := models.UnblockUser(userID, blockedID)
+	if errors != nil {
+		log.Debugf("Failed to unblock %v.", blockedID)
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v unblocked %v.", userID, blockedID)
+	respondBlockedUsers(userID, w)
+})
+
+// respondFriends responds with the complete friend list of the given user.
+func respondFriends(userID int64, w http.ResponseWriter) {
+	users, errors := models.Friends(userID)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	common.RespondSuccess(w, &models.Message{Users: users})
+}
+
+var contactFriendList = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	log.Debugf("User %v is requesting their friends.", userID)
+	respondFriends(userID, w)
+	log.Infof("User %v retrieved their friends.", userID)
+})
+
+var contactUnfriend = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	friendID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	log.Debugf("User %v is removing friend %v.", userID, friendID)
+	errors := models.RemoveFriend(userID, friendID)
+	if errors != nil {
+		log.Debugf("Failed to remove friend %v.", friendID)
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v removed friend %v.", userID, friendID)
+	respondFriends(userID, w)
+})
+
+// respondFriendRequests responds with all of the pending friend requests sent
+// and received by the given user.
+func respondFriendRequests(userID int64, w http.ResponseWriter) {
+	requests, errors := models.PendingFriendRequests(userID)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	common.RespondSuccess(w, &models.Message{FriendRequests: requests})
+}
+
+// friendRequestHandler returns a handler that applies a friend request
+// operation between the authenticated user and the user in the path, and
+// responds with the remaining pending friend requests.
+func friendRequestHandler(action string, f func(int64, int64) *models.Errors) http.HandlerFunc {
+	return common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+		otherID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+		log.Debugf("User %v: %v friend request with %v.", userID, action, otherID)
+		errors := f(userID, otherID)
+		if errors != nil {
+			log.Debugf("Failed to %v friend request with %v.", action, otherID)
+			common.RespondClientError(w, errors)
+			return
+		}
+
+		log.Infof("User %v: %v friend request with %v.", userID, action, otherID)
+		respondFriendRequests(userID, w)
+	})
+}
+
+var contactRequestList = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	log.Debugf("User %v is requesting their friend requests.", userID)
+	respondFriendRequests(userID, w)
+	log.Infof("User %v retrieved their friend requests.", userID)
+})
+
+var contactRequestSend = friendRequestHandler("send", models.SendFriendRequest)
+
+var contactRequestCancel = friendRequestHandler("cancel", models.CancelFriendRequest)
+
+var contactRequestAccept = friendRequestHandler("accept", models.AcceptFriendRequest)
+
+var contactRequestDecline = friendRequestHandler("decline", models.DeclineFriendRequest)
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"math"
 	"net/http"
 	"strconv"
 
@@ -15,11 +16,44 @@ import (
 func InstallGameHandlers(r *mux.Router) {
 	common.InstallHandler(r, "/games", gameHistory).Methods("GET")
 	common.InstallHandler(r, "/games/{id:[0-9]+}", gameById).Methods("GET")
+	common.InstallHandler(r, "/games/{id:[0-9]+}", gameCancel).Methods("DELETE")
+	common.InstallHandler(r, "/games/{id:[0-9]+}/leave", gameLeave).
+		Methods("POST")
+	common.InstallHandler(r, "/games/{id:[0-9]+}/comments", gameComments).
+		Methods("GET")
+	common.InstallHandler(r, "/games/{id:[0-9]+}/comments", gameCommentPost).
+		Methods("POST")
+	common.InstallHandler(r,
+		"/games/{id:[0-9]+}/comments/{commentID:[0-9]+}", gameCommentEdit).
+		Methods("PUT")
+	common.InstallHandler(r,
+		"/games/{id:[0-9]+}/comments/{commentID:[0-9]+}", gameCommentDelete).
+		Methods("DELETE")
+	common.InstallHandler(r, "/games/{id:[0-9]+}/rematch", gameRematch).
+		Methods("POST")
+	common.InstallHandler(r, "/games/{id:[0-9]+}/favorite", gameFavorite).
+		Methods("PUT")
+	common.InstallHandler(r, "/games/{id:[0-9]+}/favorite", gameUnfavorite).
+		Methods("DELETE")
+	common.InstallHandler(r,
+		"/games/{id:[0-9]+}/turns/{index:[0-9]+}/reactions/{emoji}",
+		gameReact).Methods("PUT")
+	common.InstallHandler(r,
+		"/games/{id:[0-9]+}/turns/{index:[0-9]+}/reactions/{emoji}",
+		gameUnreact).Methods("DELETE")
+	common.InstallHandler(r, "/games/{id:[0-9]+}/turns/{index:[0-9]+}/vote",
+		gameVote).Methods("PUT")
+	common.InstallHandler(r, "/games/{id:[0-9]+}/turns/{index:[0-9]+}/vote",
+		gameUnvote).Methods("DELETE")
+	common.InstallHandler(r, "/games/active", gameActive).Methods("GET")
+	common.InstallHandler(r, "/games/favorites", gameFavorites).Methods("GET")
 	common.InstallHandler(r, "/games/inbox", gameInbox).Methods("GET")
 	common.InstallHandler(r, "/games/inbox/{id:[0-9]+}", gameInboxById).
 		Methods("GET")
 	common.InstallHandler(r, "/games/new", gameCreate).Methods("POST")
 	common.InstallHandler(r, "/games/play/{id:[0-9]+}", gamePlay).Methods("PUT")
+	common.InstallHandler(r, "/games/play/{id:[0-9]+}/skip", gameSkip).
+		Methods("POST")
 }
 
 var gameCreate = common.AuthHandlerFunc(func(id int64, w http.ResponseWriter, r *http.Request) {
@@ -30,22 +64,46 @@ var gameCreate = common.AuthHandlerFunc(func(id int64, w http.ResponseWriter, r
 	}
 
 	log.Debugf("Attempting to start new game.")
-	errors := models.CreateGame(id, *newGame)
+	created, errors := models.CreateGame(id, *newGame)
 	if errors != nil {
 		log.Debugf("Failed to create new game.")
 		common.RespondClientError(w, errors)
 		return
 	}
 
-	log.Infof("User %v created new game.", id)
-	common.RespondSuccessNoContent(w)
+	log.Infof("User %v created new game %v.", id, created.GameID)
+	common.RespondSuccess(w, &models.Message{CreatedGame: created})
+})
+
+var gameRematch = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	rematch, err := common.RequestRematchMessage(r)
+	if err != nil {
+		common.RespondClientError(w, err)
+		return
+	}
+
+	gameID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	log.Debugf("User %v is attempting to rematch game %v.", userID, gameID)
+	created, errors := models.RematchGame(userID, gameID, *rematch)
+	if errors != nil {
+		log.Debugf("Failed to rematch game %v.", gameID)
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v rematched game %v as game %v.",
+		userID, gameID, created.GameID)
+	common.RespondSuccess(w, &models.Message{CreatedGame: created})
 })
 
 var gameInbox = common.AuthHandlerFunc(func(id int64, w http.ResponseWriter, r *http.Request) {
 	models.ReapExpiredTurns()
 
-	log.Debugf("Attempting to query users inbox.")
-	entries, errors := models.GetInboxEntriesForUser(id)
+	sortBy := r.URL.Query().Get("sort")
+
+	log.Debugf("Attempting to query users inbox sorted by %#v.", sortBy)
+	entries, errors := models.GetInboxEntriesForUser(id, sortBy)
 	if errors != nil {
 		log.Debugf("Failed to query inbox.")
 		common.RespondClientError(w, errors)
@@ -56,6 +114,21 @@ var gameInbox = common.AuthHandlerFunc(func(id int64, w http.ResponseWriter, r *
 	common.RespondSuccess(w, &models.Message{InboxEntries: entries})
 })
 
+var gameActive = common.AuthHandlerFunc(func(id int64, w http.ResponseWriter, r *http.Request) {
+	models.ReapExpiredTurns()
+
+	log.Debugf("Attempting to query users active games.")
+	games, errors := models.ActiveGames(id)
+	if errors != nil {
+		log.Debugf("Failed to query active games.")
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v retrieved active games.", id)
+	common.RespondSuccess(w, &models.Message{ActiveGames: games})
+})
+
 var gameInboxById = common.AuthHandlerFunc(func(id int64, w http.ResponseWriter, r *http.Request) {
 	gameID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 
@@ -110,28 +183,69 @@ var gamePlay = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter,
 	common.RespondSuccessNoContent(w)
 })
 
+var gameSkip = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	gameID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	log.Debugf("User %v is skipping their turn in game %v.", userID, gameID)
+	errors := models.SkipTurn(userID, gameID)
+	if errors != nil {
+		log.Debugf("User %v failed to skip turn in game %v.", userID, gameID)
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v skipped their turn in game %v.", userID, gameID)
+	common.RespondSuccessNoContent(w)
+})
+
 var gameHistory = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
-	sinceIDString := r.URL.Query().Get("since")
-	sinceID, err := strconv.ParseInt(sinceIDString, 10, 64)
+	sinceID, errors := common.RequestQueryInt(r, "since", 0)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+	beforeID, errors := common.RequestQueryInt(r, "before", 0)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+	limit, errors := common.RequestQueryInt(r, "limit", 0)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
 
-	if err != nil {
-		log.Warnf("Invalid since query parameter.")
+	hasSince := r.URL.Query().Get("since") != ""
+	if hasSince && beforeID > 0 {
+		log.Warnf("Both since and before query parameters given.")
 		common.RespondClientError(w, &models.Errors{
-			App: []string{"Invalid since ID."},
+			App: []string{"Only one of since and before may be given."},
 		})
 		return
 	}
 
-	log.Debugf("User %v is requesting history since %v.", userID, sinceID)
+	// Without a cursor the newest page is returned, which is the page before
+	// every completed game.
+	if !hasSince && beforeID == 0 {
+		beforeID = math.MaxInt64
+	}
+
+	page := models.HistoryPage{
+		SinceID:  sinceID,
+		BeforeID: beforeID,
+		Limit:    int(limit),
+	}
 
-	games, errors := models.CompletedGames(userID, sinceID)
+	log.Debugf("User %v is requesting history page %+v.", userID, page)
+
+	games, meta, errors := models.CompletedGames(userID, page)
 	if errors != nil {
 		common.RespondClientError(w, errors)
 		return
 	}
 
-	log.Infof("User %v looked up history since %v.", userID, sinceID)
-	common.RespondSuccess(w, &models.Message{Games: games})
+	log.Infof("User %v looked up history page %+v.", userID, page)
+	common.RespondSuccess(w, &models.Message{Games: games, Meta: meta})
 })
 
 var gameById = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
@@ -148,3 +262,210 @@ var gameById = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter,
 	log.Infof("User %v looked up game %v.", userID, gameID)
 	common.RespondSuccess(w, &models.Message{Game: game})
 })
+
+var gameLeave = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	gameID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	log.Debugf("User %v is leaving game %v.", userID, gameID)
+	errors := models.LeaveGame(userID, gameID)
+	if errors != nil {
+		log.Debugf("User %v failed to leave game %v.", userID, gameID)
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v left game %v.", userID, gameID)
+	common.RespondSuccessNoContent(w)
+})
+
+var gameCancel = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	gameID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	log.Debugf("User %v is cancelling game %v.", userID, gameID)
+	errors := models.CancelGame(userID, gameID)
+	if errors != nil {
+		log.Debugf("User %v failed to cancel game %v.", userID, gameID)
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v cancelled game %v.", userID, gameID)
+	common.RespondSuccessNoContent(w)
+})
+
+var gameFavorites = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	beforeID, errors := common.RequestQueryInt(r, "before", 0)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+	limit, errors := common.RequestQueryInt(r, "limit", 0)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Debugf("User %v is requesting favorites before %v.", userID, beforeID)
+
+	games, meta, errors := models.FavoriteGames(userID, beforeID, int(limit))
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v looked up favorites before %v.", userID, beforeID)
+	common.RespondSuccess(w, &models.Message{Games: games, Meta: meta})
+})
+
+var gameFavorite = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	gameID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	log.Debugf("User %v is favoriting game %v.", userID, gameID)
+	errors := models.FavoriteGame(userID, gameID)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v favorited game %v.", userID, gameID)
+	common.RespondSuccessNoContent(w)
+})
+
+var gameUnfavorite = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	gameID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	log.Debugf("User %v is unfavoriting game %v.", userID, gameID)
+	errors := models.UnfavoriteGame(userID, gameID)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v unfavorited game %v.", userID, gameID)
+	common.RespondSuccessNoContent(w)
+})
+
+// reactionHandler returns a handler that parses the game ID, 1-based turn
+// index and emoji of a reaction endpoint and passes them on to f.
+func reactionHandler(action string, f func(int64, int64, int, string) *models.Errors) http.HandlerFunc {
+	return common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		gameID, _ := strconv.ParseInt(vars["id"], 10, 64)
+		turnIndex, _ := strconv.Atoi(vars["index"])
+		emoji := vars["emoji"]
+
+		log.Debugf("User %v is attempting to %v %#v on turn %v of game %v.",
+			userID, action, emoji, turnIndex, gameID)
+		errors := f(userID, gameID, turnIndex, emoji)
+		if errors != nil {
+			common.RespondClientError(w, errors)
+			return
+		}
+
+		log.Infof("User %v %v %#v on turn %v of game %v.",
+			userID, action, emoji, turnIndex, gameID)
+		common.RespondSuccessNoContent(w)
+	})
+}
+
+var gameReact = reactionHandler("react", models.AddReaction)
+
+var gameUnreact = reactionHandler("unreact", models.RemoveReaction)
+
+var gameComments = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	gameID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	log.Debugf("User %v is requesting comments on game %v.", userID, gameID)
+	comments, errors := models.Comments(userID, gameID)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v looked up comments on game %v.", userID, gameID)
+	common.RespondSuccess(w, &models.Message{Comments: comments})
+})
+
+var gameCommentPost = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	comment, err := common.RequestCommentMessage(r)
+	if err != nil {
+		common.RespondClientError(w, err)
+		return
+	}
+
+	gameID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	log.Debugf("User %v is commenting on game %v.", userID, gameID)
+	comment, errors := models.PostComment(userID, gameID, *comment)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v commented on game %v.", userID, gameID)
+	common.RespondSuccess(w, &models.Message{Comment: comment})
+})
+
+var gameCommentEdit = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	comment, err := common.RequestCommentMessage(r)
+	if err != nil {
+		common.RespondClientError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	gameID, _ := strconv.ParseInt(vars["id"], 10, 64)
+	commentID, _ := strconv.ParseInt(vars["commentID"], 10, 64)
+
+	log.Debugf("User %v is editing comment %v.", userID, commentID)
+	comment, errors := models.EditComment(userID, gameID, commentID, comment.Body)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v edited comment %v.", userID, commentID)
+	common.RespondSuccess(w, &models.Message{Comment: comment})
+})
+
+var gameCommentDelete = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID, _ := strconv.ParseInt(vars["id"], 10, 64)
+	commentID, _ := strconv.ParseInt(vars["commentID"], 10, 64)
+
+	log.Debugf("User %v is deleting comment %v.", userID, commentID)
+	errors := models.DeleteComment(userID, gameID, commentID)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v deleted comment %v.", userID, commentID)
+	common.RespondSuccessNoContent(w)
+})
+
+// voteHandler returns a handler that parses the game ID and 1-based turn index
+// of a vote endpoint and passes them on to f.
+func voteHandler(action string, f func(int64, int64, int) *models.Errors) http.HandlerFunc {
+	return common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		gameID, _ := strconv.ParseInt(vars["id"], 10, 64)
+		turnIndex, _ := strconv.Atoi(vars["index"])
+
+		log.Debugf("User %v is attempting to %v turn %v of game %v.",
+			userID, action, turnIndex, gameID)
+		errors := f(userID, gameID, turnIndex)
+		if errors != nil {
+			common.RespondClientError(w, errors)
+			return
+		}
+
+		log.Infof("User %v %v turn %v of game %v.",
+			userID, action, turnIndex, gameID)
+		common.RespondSuccessNoContent(w)
+	})
+}
+
+var gameVote = voteHandler("vote for", models.CastVote)
+
+var gameUnvote = voteHandler("remove vote for", models.RemoveVote)
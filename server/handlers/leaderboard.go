@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/handlers/common"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/models"
+	"github.com/gorilla/mux"
+)
+
+// InstallLeaderboardHandlers takes a gorilla router and installs
+// /leaderboards/* endpoints on it.
+func InstallLeaderboardHandlers(r *mux.Router) {
+	common.InstallHandler(r, "/leaderboards", leaderboard).Methods("GET")
+}
+
+var leaderboard = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	season, errors := common.RequestQueryInt(r, "season", models.CurrentSeason())
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+	groupID, errors := common.RequestQueryInt(r, "group", 0)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Debugf("User %v is requesting the leaderboard of season %v, group %v.",
+		userID, season, groupID)
+	board, errors := models.GetLeaderboard(userID, season, groupID)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v looked up the leaderboard of season %v.", userID, season)
+	common.RespondSuccess(w, &models.Message{Leaderboard: board})
+})
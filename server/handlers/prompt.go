@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/handlers/common"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/models"
+	"github.com/gorilla/mux"
+)
+
+// InstallPromptHandlers takes a gorilla router and installs /prompts/*
+// endpoints on it.
+func InstallPromptHandlers(r *mux.Router) {
+	common.InstallHandler(r, "/prompts/random", promptRandom).Methods("GET")
+}
+
+var promptRandom = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	filter := models.PromptFilter{
+		Category:   r.URL.Query().Get("category"),
+		Difficulty: r.URL.Query().Get("difficulty"),
+		Locale:     r.URL.Query().Get("locale"),
+	}
+
+	log.Debugf("User %v is requesting a random prompt matching %+v.",
+		userID, filter)
+	prompt, errors := models.RandomPrompt(filter)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v received prompt %v.", userID, prompt.ID)
+	common.RespondSuccess(w, &models.Message{Prompt: prompt})
+})
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/handlers/common"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/models"
+	"github.com/gorilla/mux"
+)
+
+// InstallScheduleHandlers takes a gorilla router and installs /schedules/*
+// endpoints on it.
+func InstallScheduleHandlers(r *mux.Router) {
+	common.InstallHandler(r, "/schedules", scheduleList).Methods("GET")
+	common.InstallHandler(r, "/schedules", scheduleCreate).Methods("POST")
+	common.InstallHandler(r, "/schedules/{id:[0-9]+}", scheduleDelete).
+		Methods("DELETE")
+	common.InstallHandler(r, "/schedules/{id:[0-9]+}/pause", schedulePause).
+		Methods("POST")
+	common.InstallHandler(r, "/schedules/{id:[0-9]+}/resume", scheduleResume).
+		Methods("POST")
+}
+
+var scheduleList = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	log.Debugf("User %v is requesting their schedules.", userID)
+	schedules, errors := models.Schedules(userID)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v looked up their schedules.", userID)
+	common.RespondSuccess(w, &models.Message{Schedules: schedules})
+})
+
+var scheduleCreate = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	schedule, err := common.RequestScheduleMessage(r)
+	if err != nil {
+		common.RespondClientError(w, err)
+		return
+	}
+
+	log.Debugf("User %v is creating a schedule.", userID)
+	schedule, errors := models.CreateSchedule(userID, *schedule)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v created schedule %v.", userID, schedule.ID)
+	common.RespondSuccess(w, &models.Message{Schedule: schedule})
+})
+
+var scheduleDelete = common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+	scheduleID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	log.Debugf("User %v is deleting schedule %v.", userID, scheduleID)
+	errors := models.DeleteSchedule(userID, scheduleID)
+	if errors != nil {
+		common.RespondClientError(w, errors)
+		return
+	}
+
+	log.Infof("User %v deleted schedule %v.", userID, scheduleID)
+	common.RespondSuccessNoContent(w)
+})
+
+// schedulePausedHandler returns a handler that pauses or resumes the schedule
+// in the request path.
+func schedulePausedHandler(paused bool) http.HandlerFunc {
+	return common.AuthHandlerFunc(func(userID int64, w http.ResponseWriter, r *http.Request) {
+		scheduleID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+		log.Debugf("User %v is setting schedule %v paused to %v.",
+			userID, scheduleID, paused)
+		errors := models.SetSchedulePaused(userID, scheduleID, paused)
+		if errors != nil {
+			common.RespondClientError(w, errors)
+			return
+		}
+
+		log.Infof("User %v set schedule %v paused to %v.",
+			userID, scheduleID, paused)
+		common.RespondSuccessNoContent(w)
+	})
+}
+
+var schedulePause = schedulePausedHandler(true)
+
+var scheduleResume = schedulePausedHandler(false)
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"database/sql"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/db"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+)
+
+// ActiveGame summarizes the progress of a game that has not yet completed. It
+// deliberately contains no turn content, which is only revealed once the game
+// is over.
+type ActiveGame struct {
+	GameID         int64  `json:"game_id,omitempty"`
+	Creator        string `json:"creator,omitempty"`
+	CreatedAt      int64  `json:"created_at,omitempty"`
+	CurrentPlayer  string `json:"current_player,omitempty"`
+	NextExpiration int64  `json:"next_expiration,omitempty"`
+	TimeRemaining  int64  `json:"time_remaining"`
+	TurnDuration   int64  `json:"turn_duration,omitempty"`
+	CompletedTurns int    `json:"completed_turns"`
+	TotalTurns     int    `json:"total_turns,omitempty"`
+
+	// TakenTurns contains the 1-based indices of the turns that the requesting
+	// user has already taken in this game.
+	TakenTurns []int `json:"taken_turns,omitempty"`
+}
+
+// ActiveGames returns a summary of every game that the given user is
+// participating in and that has not yet completed, ordered by game ID.
+func ActiveGames(userID int64) ([]ActiveGame, *Errors) {
+	var games []ActiveGame
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		log.Debugf("Querying active games of user %v.", userID)
+		rows, err := db.Query(
+			`SELECT
+			    Games.id,
+			    UNIX_TIMESTAMP(Games.created_at),
+			    UNIX_TIMESTAMP(Games.next_expiration),
+			    GREATEST(0, TIMESTAMPDIFF(SECOND, NOW(), Games.next_expiration)),
+			    Games.turn_duration,
+			    Turns.account_id,
+			    Accounts.display_name,
+			    Turns.is_complete
+			 FROM Games
+			 INNER JOIN Turns ON Turns.game_id = Games.id
+			 INNER JOIN Accounts ON Accounts.id = Turns.account_id
+			 WHERE Games.completed_at_id IS NULL
+			   AND Games.id IN (SELECT game_id FROM Turns WHERE account_id = ?)
+			 ORDER BY Games.id ASC, Turns.id ASC`,
+			userID)
+		if err != nil {
+			log.Warnf("Unable to query active games, %v.", err)
+			errors = &Errors{App: []string{"Unable to query games at this time."}}
+			return
+		}
+		defer rows.Close()
+
+		games = make([]ActiveGame, 0)
+		for rows.Next() {
+			var game ActiveGame
+			var accountID int64
+			var player string
+			var isComplete bool
+			err := rows.Scan(
+				&game.GameID, &game.CreatedAt, &game.NextExpiration,
+				&game.TimeRemaining, &game.TurnDuration,
+				&accountID, &player, &isComplete)
+			if err != nil {
+				log.Warnf("Unable to scan row, %v.", err.Error())
+				continue
+			}
+
+			// Rows are ordered by game and then by turn, so the first row of each
+			// game is the label written by its creator.
+			if len(games) == 0 || games[len(games)-1].GameID != game.GameID {
+				game.Creator = player
+				games = append(games, game)
+			}
+
+			last := &games[len(games)-1]
+			last.TotalTurns++
+			if !isComplete {
+				if last.CurrentPlayer == "" {
+					last.CurrentPlayer = player
+				}
+				continue
+			}
+
+			last.CompletedTurns++
+			if accountID == userID {
+				last.TakenTurns = append(last.TakenTurns, last.TotalTurns)
+			}
+		}
+	})
+
+	return games, errors
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"database/sql"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/db"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+)
+
+// notBlockedClause is a SQL condition that holds if neither the account in the
+// given column nor the user bound to both placeholders has blocked the other.
+func notBlockedClause(column string) string {
+	return `NOT EXISTS (
+	            SELECT 1 FROM Blocks
+	            WHERE (Blocks.account_id = ? AND Blocks.blocked_id = ` + column + `)
+	               OR (Blocks.account_id = ` + column + ` AND Blocks.blocked_id = ?)
+	        )`
+}
+
+// BlockedUsers returns the users that the given user has blocked ordered by
+// display name.
+func BlockedUsers(userID int64) ([]User, *Errors) {
+	var users []User
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		log.Debugf("Querying blocked users of user %v.", userID)
+		rows, err := db.Query(
+			`SELECT Accounts.id, Accounts.display_name
+			 FROM Blocks
+			 INNER JOIN Accounts ON Accounts.id = Blocks.blocked_id
+			 WHERE Blocks.account_id = ?
+			 ORDER BY Accounts.display_name ASC`,
+			userID)
+		if err != nil {
+			log.Warnf("Unable to query blocked users, %v.", err)
+			errors = &Errors{App: []string{"Unable to query blocked players at this time."}}
+			return
+		}
+
+		users = rowsToUsers(rows)
+	})
+
+	return users, errors
+}
+
+// BlockUser prevents the user with ID blockedID from finding or starting games
+// with the user with ID userID, and vice versa. The blocked user is also
+// removed from the user's contacts and contact groups, and any friendship or
+// friend request between the two is deleted.
+func BlockUser(userID, blockedID int64) *Errors {
+	if userID == blockedID {
+		return &Errors{App: []string{"You cannot block yourself."}}
+	}
+
+	var errors *Errors
+	db.WithTx(func(tx *sql.Tx) error {
+		log.Debugf("User %v is blocking user %v.", userID, blockedID)
+		var exists bool
+		row := tx.QueryRow("SELECT COUNT(*) > 0 FROM Accounts WHERE id = ?", blockedID)
+		err := row.Scan(&exists)
+		if err != nil || !exists {
+			log.Debugf("Unable to block unknown user %v.", blockedID)
+			errors = &Errors{App: []string{"No such user."}}
+			return err
+		}
+
+		_, err = tx.Exec(
+			`INSERT IGNORE INTO Blocks (account_id, blocked_id, created_at)
+			 VALUES (?, ?, NOW())`,
+			userID, blockedID)
+		if err == nil {
+			_, err = tx.Exec(
+				"DELETE FROM Contacts WHERE account_id = ? AND contact_id = ?",
+				userID, blockedID)
+		}
+		if err == nil {
+			_, err = tx.Exec(
+				`DELETE FROM FriendRequests
+				 WHERE (sender_id = ? AND recipient_id = ?)
+				    OR (sender_id = ? AND recipient_id = ?)`,
+				userID, blockedID, blockedID, userID)
+		}
+		if err == nil {
+			_, err = tx.Exec(
+				`DELETE Members FROM ContactGroupMembers AS Members
+				 INNER JOIN ContactGroups AS CG ON CG.id = Members.group_id
+				 WHERE CG.account_id = ? AND Members.account_id = ?`,
+				userID, blockedID)
+		}
+		if err != nil {
+			log.Warnf("Unable to block user, %v.", err)
+			errors = &Errors{App: []string{"Unable to block player at this time."}}
+			return err
+		}
+
+		return nil
+	})
+
+	return errors
+}
+
+// UnblockUser removes the user with ID blockedID from the block list of the
+// user with ID userID.
+func UnblockUser(userID, blockedID int64) *Errors {
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		log.Debugf("User %v is unblocking user %v.", userID, blockedID)
+		_, err := db.Exec(
+			"DELETE FROM Blocks WHERE account_id = ? AND blocked_id = ?",
+			userID, blockedID)
+		if err != nil {
+			log.Warnf("Unable to unblock user, %v.", err)
+			errors = &Errors{App: []string{"Unable to unblock player at this time."}}
+		}
+	})
+
+	return errors
+}
+
+// blockedAccounts returns the set of accounts that the given user has blocked
+// and the set of accounts that have blocked the given user.
+func blockedAccounts(tx *sql.Tx, userID int64) (blocked, blockedBy map[int64]bool, err error) {
+	rows, err := tx.Query(
+		`SELECT account_id, blocked_id FROM Blocks
+		 WHERE account_id = ? OR blocked_id = ?`,
+		userID, userID)
+	if err != nil {
+		log.Warnf("Unable to query blocks, %v.", err)
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	blocked = make(map[int64]bool)
+	blockedBy = make(map[int64]bool)
+	for rows.Next() {
+		var accountID, blockedID int64
+		if err := rows.Scan(&accountID, &blockedID); err != nil {
+			return nil, nil, err
+		}
+
+		if accountID == userID {
+			blocked[blockedID] = true
+		} else {
+			blockedBy[accountID] = true
+		}
+	}
+	return blocked, blockedBy, nil
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"database/sql"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/models/common"
+)
+
+// linkCircleInTx joins the given games into a circle. Every game of a circle
+// stores the ID of its first game as its circle ID. The games of a circle are
+// completed together, see updateGameCompletedAtTimeInTx, and are grouped into
+// a single entry in the history, see groupCircles.
+func linkCircleInTx(tx *sql.Tx, gameIDs []int64) error {
+	args := make([]interface{}, 0, len(gameIDs)+1)
+	args = append(args, gameIDs[0])
+	for _, id := range gameIDs {
+		args = append(args, id)
+	}
+
+	_, err := tx.Exec(
+		`UPDATE Games SET circle_id = ?
+		 WHERE id IN (`+common.Placeholders(len(gameIDs))+`)`,
+		args...)
+	return err
+}
+
+// gameSetID returns the ID shared by every game that completes together with
+// the given game. This is the game's circle ID, or its own ID if it is not part
+// of a circle. The set consists of the games matching "id = ? OR circle_id = ?"
+// with the set ID bound to both placeholders.
+func gameSetID(tx *sql.Tx, gameID int64) (int64, error) {
+	var setID int64
+	row := tx.QueryRow("SELECT IFNULL(circle_id, id) FROM Games WHERE id = ?", gameID)
+	err := row.Scan(&setID)
+	return setID, err
+}
+
+// groupCircles replaces the games of each circle with a single game that
+// contains them as its chains, at the position of the circle's first game.
+func groupCircles(games []Game) []Game {
+	grouped := make([]Game, 0, len(games))
+	circles := make(map[int64]int)
+	for _, game := range games {
+		if game.CircleID == 0 {
+			grouped = append(grouped, game)
+			continue
+		}
+
+		i, ok := circles[game.CircleID]
+		if !ok {
+			i = len(grouped)
+			circles[game.CircleID] = i
+			grouped = append(grouped, Game{
+				ID:            game.CircleID,
+				CompletedAt:   game.CompletedAt,
+				CompletedAtID: game.CompletedAtID,
+				TurnDuration:  game.TurnDuration,
+				CircleID:      game.CircleID,
+				VotingEndsAt:  game.VotingEndsAt,
+			})
+		}
+		grouped[i].Chains = append(grouped[i].Chains, game)
+	}
+	return grouped
+}
@@ -0,0 +1,224 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/db"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/models/common"
+)
+
+const maxCommentLength = 1000
+
+// Comment is a single message in the discussion thread of a completed game. A
+// comment may optionally be anchored to a turn of the game by its 1-based turn
+// index.
+type Comment struct {
+	ID        int64  `json:"id,omitempty"`
+	Author    string `json:"author,omitempty"`
+	Body      string `json:"body,omitempty"`
+	TurnIndex int    `json:"turn_index,omitempty"`
+	CreatedAt int64  `json:"created_at,omitempty"`
+	EditedAt  int64  `json:"edited_at,omitempty"`
+}
+
+func validateCommentBody(body string) (string, *Errors) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", &Errors{App: []string{"Comment must be non-empty."}}
+	}
+	if utf8.RuneCountInString(body) > maxCommentLength {
+		return "", &Errors{App: []string{"Comment must be at most 1000 characters."}}
+	}
+	return body, nil
+}
+
+const commentQuery = `
+	SELECT
+	    Comments.id,
+	    Accounts.display_name,
+	    Comments.body,
+	    IFNULL(Comments.turn_index, 0),
+	    UNIX_TIMESTAMP(Comments.created_at),
+	    IFNULL(UNIX_TIMESTAMP(Comments.edited_at), 0)
+	FROM Comments
+	INNER JOIN Accounts ON Accounts.id = Comments.account_id`
+
+func rowToComment(row common.Scannable) (*Comment, error) {
+	comment := &Comment{}
+	err := row.Scan(
+		&comment.ID, &comment.Author, &comment.Body, &comment.TurnIndex,
+		&comment.CreatedAt, &comment.EditedAt)
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// Comments returns the discussion thread of a completed game in the order in
+// which the comments were posted. Only participants of the game may read it.
+func Comments(userID, gameID int64) ([]Comment, *Errors) {
+	var comments []Comment
+	var errors *Errors
+	errMsg := []string{"Unable to query comments at this time."}
+	db.WithTx(func(tx *sql.Tx) error {
+		errors = checkCompletedGameParticipant(tx, userID, gameID)
+		if errors != nil {
+			return errors
+		}
+
+		rows, err := tx.Query(
+			commentQuery+` WHERE Comments.game_id = ? ORDER BY Comments.id ASC`,
+			gameID)
+		if err != nil {
+			log.Warnf("Unable to look up comments, %v.", err)
+			errors = &Errors{App: errMsg}
+			return err
+		}
+		defer rows.Close()
+
+		comments = make([]Comment, 0, 8)
+		for rows.Next() {
+			comment, err := rowToComment(rows)
+			if err != nil {
+				log.Warnf("Unable to scan row, %v.", err.Error())
+				continue
+			}
+			comments = append(comments, *comment)
+		}
+
+		return nil
+	})
+
+	return comments, errors
+}
+
+// PostComment adds a comment by the given user to the discussion thread of a
+// completed game they participated in. If comment.TurnIndex is non-zero, it
+// must refer to a turn of the game.
+func PostComment(userID, gameID int64, comment Comment) (*Comment, *Errors) {
+	body, errors := validateCommentBody(comment.Body)
+	if errors != nil {
+		return nil, errors
+	}
+
+	var posted *Comment
+	errMsg := []string{"Unable to post comment at this time."}
+	db.WithTx(func(tx *sql.Tx) error {
+		errors = checkCompletedGameParticipant(tx, userID, gameID)
+		if errors != nil {
+			return errors
+		}
+
+		var turnIndex interface{}
+		if comment.TurnIndex != 0 {
+			_, errors = turnIDByIndex(tx, gameID, comment.TurnIndex)
+			if errors != nil {
+				return errors
+			}
+			turnIndex = comment.TurnIndex
+		}
+
+		log.Debugf("User %v commenting on game %v.", userID, gameID)
+		res, err := tx.Exec(
+			`INSERT INTO Comments (game_id, account_id, body, turn_index, created_at)
+			 VALUES (?, ?, ?, ?, NOW())`,
+			gameID, userID, body, turnIndex)
+		if err != nil {
+			log.Warnf("Unable to insert comment, %v.", err)
+			errors = &Errors{App: errMsg}
+			return err
+		}
+
+		commentID, err := res.LastInsertId()
+		if err != nil {
+			log.Warnf("Unable to get comment ID, %v.", err)
+			errors = &Errors{App: errMsg}
+			return err
+		}
+
+		posted, err = rowToComment(tx.QueryRow(
+			commentQuery+` WHERE Comments.id = ?`, commentID))
+		if err != nil {
+			log.Warnf("Unable to look up new comment, %v.", err)
+			errors = &Errors{App: errMsg}
+			return err
+		}
+
+		return nil
+	})
+
+	if errors != nil {
+		return nil, errors
+	}
+	return posted, nil
+}
+
+// EditComment replaces the body of one of the given user's own comments.
+func EditComment(userID, gameID, commentID int64, body string) (*Comment, *Errors) {
+	body, errors := validateCommentBody(body)
+	if errors != nil {
+		return nil, errors
+	}
+
+	var edited *Comment
+	errMsg := []string{"Unable to edit comment at this time."}
+	db.WithTx(func(tx *sql.Tx) error {
+		log.Debugf("User %v editing comment %v.", userID, commentID)
+		_, err := tx.Exec(
+			`UPDATE Comments SET body = ?, edited_at = NOW()
+			 WHERE id = ? AND game_id = ? AND account_id = ?`,
+			body, commentID, gameID, userID)
+		if err != nil {
+			log.Warnf("Unable to edit comment, %v.", err)
+			errors = &Errors{App: errMsg}
+			return err
+		}
+
+		// Editing a comment to its current body affects no rows, so ownership is
+		// checked by looking the comment back up instead.
+		edited, err = rowToComment(tx.QueryRow(
+			commentQuery+` WHERE Comments.id = ?
+			                 AND Comments.game_id = ?
+			                 AND Comments.account_id = ?`,
+			commentID, gameID, userID))
+		if err != nil {
+			log.Debugf("User %v has no comment %v in game %v.", userID, commentID, gameID)
+			errors = &Errors{App: []string{"No such comment."}}
+			return err
+		}
+
+		return nil
+	})
+
+	if errors != nil {
+		return nil, errors
+	}
+	return edited, nil
+}
+
+// DeleteComment removes one of the given user's own comments.
+func DeleteComment(userID, gameID, commentID int64) *Errors {
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		log.Debugf("User %v deleting comment %v.", userID, commentID)
+		res, err := db.Exec(
+			"DELETE FROM Comments WHERE id = ? AND game_id = ? AND account_id = ?",
+			commentID, gameID, userID)
+		if err != nil {
+			log.Warnf("Unable to delete comment, %v.", err)
+			errors = &Errors{App: []string{"Unable to delete comment at this time."}}
+			return
+		}
+
+		i, err := res.RowsAffected()
+		if i <= 0 || err != nil {
+			log.Debugf("User %v has no comment %v in game %v.", userID, commentID, gameID)
+			errors = &Errors{App: []string{"No such comment."}}
+		}
+	})
+
+	return errors
+}
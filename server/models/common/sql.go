@@ -0,0 +1,14 @@
+package common
+
+import (
+	"strings"
+)
+
+// Placeholders returns a comma separated list of n SQL placeholders for use in
+// an IN clause, e.g. "?, ?, ?" for n = 3.
+func Placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strings.Repeat("?, ", n-1) + "?"
+}
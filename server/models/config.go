@@ -0,0 +1,97 @@
+package models
+
+import (
+	"sync"
+	"time"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+)
+
+// Config defines all the settings that control the rules enforced by the
+// models.
+type Config struct {
+	PasswordPolicy PasswordPolicy
+
+	// MinTurnDuration and MaxTurnDuration bound the turn duration that can be
+	// requested when creating a game. DefaultTurnDuration is used when a game
+	// does not request one.
+	MinTurnDuration     time.Duration
+	MaxTurnDuration     time.Duration
+	DefaultTurnDuration time.Duration
+
+	// DailySkipAllowance is the number of turns a player may voluntarily skip in
+	// any 24 hour period. Zero disables voluntary skips.
+	DailySkipAllowance int
+
+	// VotingWindow is how long after a game completes its participants may vote
+	// for its best drawing and best label.
+	VotingWindow time.Duration
+
+	// Seasons are consecutive periods of SeasonLength beginning at SeasonStart.
+	// Points from votes count towards the season in which the game completed.
+	SeasonStart  time.Time
+	SeasonLength time.Duration
+}
+
+var config = Config{
+	PasswordPolicy:      defaultPasswordPolicy,
+	MinTurnDuration:     5 * time.Minute,
+	MaxTurnDuration:     7 * 24 * time.Hour,
+	DefaultTurnDuration: 2 * 24 * time.Hour,
+	DailySkipAllowance:  3,
+	VotingWindow:        3 * 24 * time.Hour,
+	SeasonStart:         time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC),
+	SeasonLength:        91 * 24 * time.Hour,
+}
+var configOnce sync.Once
+
+// Init configures the models. If Init is never called then the models will use
+// the default configuration. Any zero valued durations are replaced with their
+// defaults.
+func Init(c Config) {
+	configOnce.Do(func() {
+		log.Infof("Initializing models.")
+
+		c.PasswordPolicy = initPasswordPolicy(c.PasswordPolicy)
+
+		if c.MinTurnDuration <= 0 {
+			c.MinTurnDuration = config.MinTurnDuration
+		}
+		if c.MaxTurnDuration <= 0 {
+			c.MaxTurnDuration = config.MaxTurnDuration
+		}
+		if c.DefaultTurnDuration <= 0 {
+			c.DefaultTurnDuration = config.DefaultTurnDuration
+		}
+		if c.MinTurnDuration > c.DefaultTurnDuration ||
+			c.DefaultTurnDuration > c.MaxTurnDuration {
+			log.Fatalf("The default turn duration %v is not between %v and %v.",
+				c.DefaultTurnDuration, c.MinTurnDuration, c.MaxTurnDuration)
+		}
+
+		if c.VotingWindow <= 0 {
+			c.VotingWindow = config.VotingWindow
+		}
+		if c.SeasonStart.IsZero() {
+			c.SeasonStart = config.SeasonStart
+		}
+		if c.SeasonLength <= 0 {
+			c.SeasonLength = config.SeasonLength
+		}
+
+		log.Verbosef("Setting the model config as follows:")
+		log.Verbosef("{ PasswordPolicy.MinLength: %v", c.PasswordPolicy.MinLength)
+		log.Verbosef(", PasswordPolicy.MaxLength: %v", c.PasswordPolicy.MaxLength)
+		log.Verbosef(", PasswordPolicy.BreachedPasswordsDir: %v",
+			c.PasswordPolicy.BreachedPasswordsDir)
+		log.Verbosef(", MinTurnDuration: %v", c.MinTurnDuration)
+		log.Verbosef(", MaxTurnDuration: %v", c.MaxTurnDuration)
+		log.Verbosef(", DefaultTurnDuration: %v", c.DefaultTurnDuration)
+		log.Verbosef(", DailySkipAllowance: %v", c.DailySkipAllowance)
+		log.Verbosef(", VotingWindow: %v", c.VotingWindow)
+		log.Verbosef(", SeasonStart: %v", c.SeasonStart)
+		log.Verbosef(", SeasonLength: %v }", c.SeasonLength)
+
+		config = c
+	})
+}
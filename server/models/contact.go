@@ -2,29 +2,259 @@ package models
 
 import (
 	"database/sql"
+	"strconv"
+	"strings"
 
 	"github.com/GreatestGuys/pifuxelck-server-go/server/db"
 	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/models/common"
 )
 
-// ContactLookup looks up a user given a display name.
-func ContactLookup(name string) (user *User, userErr *UserError) {
+// MaxContactLookupNames is the maximum number of display names that can be
+// resolved by a single call to ContactLookupBatch.
+const MaxContactLookupNames = 100
+
+// ContactLookup looks up a user given a display name on behalf of the user
+// with ID userID. If no user currently has the display name, then the user that
+// most recently gave up the name is returned, provided that the rename happened
+// recently. Users that have blocked or been blocked by userID are not found.
+func ContactLookup(userID int64, name string) (user *User, userErr *UserError) {
+	name = normalizeDisplayName(name)
+
 	db.WithDB(func(db *sql.DB) {
 		log.Debugf("Looking up user by display name %#v.", name)
 
-		row := db.QueryRow("SELECT id FROM Accounts WHERE display_name = ?", name)
+		row := db.QueryRow(
+			`SELECT id, display_name FROM (
+			    SELECT id, display_name, 0 AS priority, NOW() AS changed_at
+			    FROM Accounts
+			    WHERE display_name = ?
+			    UNION ALL
+			    SELECT Accounts.id, Accounts.display_name, 1, History.changed_at
+			    FROM DisplayNameHistory AS History
+			    INNER JOIN Accounts ON Accounts.id = History.account_id
+			    WHERE History.display_name = ?
+			      AND History.changed_at > NOW() - `+displayNameHistoryWindow+`
+			 ) AS Names
+			 WHERE `+notBlockedClause("Names.id")+`
+			 ORDER BY priority ASC, changed_at DESC
+			 LIMIT 1`,
+			name, name, userID, userID)
 
 		var id int64
-		err := row.Scan(&id)
+		var displayName string
+		err := row.Scan(&id, &displayName)
 		if err != nil {
 			log.Debugf("Unable to find user %#v.", name)
 			userErr = &UserError{DisplayName: []string{"No such user."}}
 			return
 		}
 
-		log.Debugf("Unable to find user %#v with id %v.", name, id)
-		user = &User{DisplayName: name, ID: id}
+		log.Debugf("Found user %#v with id %v.", displayName, id)
+		user = &User{DisplayName: displayName, ID: id}
 	})
 
 	return user, userErr
 }
+
+// ContactLookupBatch looks up several users by display name on behalf of the
+// user with ID userID using a single query. It returns the users that were
+// found in the order they were requested, and an error for each requested
+// name that could not be resolved keyed by that name. As with ContactLookup,
+// recently given up display names resolve to their previous owner.
+func ContactLookupBatch(userID int64, names []string) ([]User, map[string]*UserError, *Errors) {
+	if len(names) == 0 {
+		return nil, nil, &Errors{App: []string{"At least one display name is required."}}
+	}
+
+	if len(names) > MaxContactLookupNames {
+		return nil, nil, &Errors{App: []string{
+			"At most " + strconv.Itoa(MaxContactLookupNames) +
+				" display names can be looked up at once."}}
+	}
+
+	// Display names are compared case insensitively, so the query and the
+	// results are keyed by the lower cased normalized name.
+	keys := make([]string, len(names))
+	var distinct []interface{}
+	seen := make(map[string]bool)
+	for i, name := range names {
+		keys[i] = strings.ToLower(normalizeDisplayName(name))
+		if !seen[keys[i]] {
+			seen[keys[i]] = true
+			distinct = append(distinct, keys[i])
+		}
+	}
+	placeholders := common.Placeholders(len(distinct))
+	args := make([]interface{}, 0, 2*len(distinct)+2)
+	args = append(args, distinct...)
+	args = append(args, distinct...)
+	args = append(args, userID, userID)
+
+	found := make(map[string]User)
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		log.Debugf("Looking up %v users by display name.", len(names))
+		// Each name is matched against current display names first, and then
+		// against the most recently given up display names, as in ContactLookup.
+		rows, err := db.Query(
+			`SELECT name, id, display_name FROM (
+			    SELECT display_name AS name, id, display_name,
+			           0 AS priority, NOW() AS changed_at
+			    FROM Accounts
+			    WHERE display_name IN (`+placeholders+`)
+			    UNION ALL
+			    SELECT History.display_name, Accounts.id, Accounts.display_name,
+			           1, History.changed_at
+			    FROM DisplayNameHistory AS History
+			    INNER JOIN Accounts ON Accounts.id = History.account_id
+			    WHERE History.display_name IN (`+placeholders+`)
+			      AND History.changed_at > NOW() - `+displayNameHistoryWindow+`
+			 ) AS Names
+			 WHERE `+notBlockedClause("Names.id")+`
+			 ORDER BY priority ASC, changed_at DESC`,
+			args...)
+		if err != nil {
+			log.Warnf("Unable to look up users, %v.", err)
+			errors = &Errors{App: []string{"Unable to look up contacts at this time."}}
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var name string
+			var user User
+			if err := rows.Scan(&name, &user.ID, &user.DisplayName); err != nil {
+				log.Warnf("Unable to scan row, %v.", err.Error())
+				continue
+			}
+			key := strings.ToLower(name)
+			if _, ok := found[key]; !ok {
+				found[key] = user
+			}
+		}
+	})
+
+	if errors != nil {
+		return nil, nil, errors
+	}
+
+	users := make([]User, 0, len(found))
+	userErrs := make(map[string]*UserError)
+	added := make(map[int64]bool)
+	for i, name := range names {
+		user, ok := found[keys[i]]
+		if !ok {
+			userErrs[name] = &UserError{DisplayName: []string{"No such user."}}
+			continue
+		}
+
+		if !added[user.ID] {
+			added[user.ID] = true
+			users = append(users, user)
+		}
+	}
+
+	log.Debugf("Resolved %v of %v display names.", len(users), len(names))
+	return users, userErrs, nil
+}
+
+// Contacts returns the saved contacts of the given user ordered by display
+// name.
+func Contacts(userID int64) ([]User, *Errors) {
+	var users []User
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		log.Debugf("Querying contacts of user %v.", userID)
+		rows, err := db.Query(
+			`SELECT Accounts.id, Accounts.display_name
+			 FROM Contacts
+			 INNER JOIN Accounts ON Accounts.id = Contacts.contact_id
+			 WHERE Contacts.account_id = ?
+			 ORDER BY Accounts.display_name ASC`,
+			userID)
+		if err != nil {
+			log.Warnf("Unable to query contacts, %v.", err)
+			errors = &Errors{App: []string{"Unable to query contacts at this time."}}
+			return
+		}
+
+		users = rowsToUsers(rows)
+	})
+
+	return users, errors
+}
+
+// AddContact saves the user with ID contactID to the contacts of the user with
+// ID userID. Adding an existing contact is not an error.
+func AddContact(userID, contactID int64) *Errors {
+	if userID == contactID {
+		return &Errors{App: []string{"You cannot add yourself as a contact."}}
+	}
+
+	var errors *Errors
+	db.WithTx(func(tx *sql.Tx) error {
+		log.Debugf("Adding contact %v to user %v.", contactID, userID)
+		res, err := tx.Exec(
+			`INSERT IGNORE INTO Contacts (account_id, contact_id, created_at)
+			 SELECT ?, id, NOW() FROM Accounts WHERE id = ?`,
+			userID, contactID)
+		if err != nil {
+			log.Warnf("Unable to add contact, %v.", err)
+			errors = &Errors{App: []string{"Unable to add contact at this time."}}
+			return err
+		}
+
+		// Zero affected rows is either an unknown user or a contact that has
+		// already been saved, only the former is an error.
+		if i, _ := res.RowsAffected(); i > 0 {
+			return nil
+		}
+
+		var exists bool
+		row := tx.QueryRow("SELECT COUNT(*) > 0 FROM Accounts WHERE id = ?", contactID)
+		err = row.Scan(&exists)
+		if err != nil || !exists {
+			log.Debugf("Unable to add unknown contact %v.", contactID)
+			errors = &Errors{App: []string{"No such user."}}
+		}
+
+		return err
+	})
+
+	return errors
+}
+
+// RemoveContact removes the user with ID contactID from the contacts of the
+// user with ID userID. Removing a user that is not a contact is not an error.
+func RemoveContact(userID, contactID int64) *Errors {
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		log.Debugf("Removing contact %v from user %v.", contactID, userID)
+		_, err := db.Exec(
+			"DELETE FROM Contacts WHERE account_id = ? AND contact_id = ?",
+			userID, contactID)
+		if err != nil {
+			log.Warnf("Unable to remove contact, %v.", err)
+			errors = &Errors{App: []string{"Unable to remove contact at this time."}}
+		}
+	})
+
+	return errors
+}
+
+func rowsToUsers(rows *sql.Rows) []User {
+	defer rows.Close()
+
+	users := make([]User, 0)
+	for rows.Next() {
+		var user User
+		err := rows.Scan(&user.ID, &user.DisplayName)
+		if err != nil {
+			log.Warnf("Unable to scan row, %v.", err.Error())
+			continue
+		}
+		users = append(users, user)
+	}
+	return users
+}
@@ -0,0 +1,332 @@
+package models
+
+import (
+	"database/sql"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/db"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/models/common"
+)
+
+const maxContactGroupNameLength = 64
+
+// ContactGroup is a named set of players that can be used to quickly start a
+// game with the same people.
+type ContactGroup struct {
+	ID      int64  `json:"id,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Members []User `json:"members,omitempty"`
+}
+
+// ContactGroupError is an error type that is returned when there is a problem
+// validating a contact group.
+type ContactGroupError struct {
+	Name    []string `json:"name,omitempty"`
+	Members []string `json:"members,omitempty"`
+}
+
+func (e ContactGroupError) Error() string {
+	return common.ModelErrorHelper(e)
+}
+
+func validateContactGroupName(name string) *Errors {
+	if name == "" {
+		return &Errors{ContactGroup: &ContactGroupError{
+			Name: []string{"A group name is required."},
+		}}
+	}
+
+	if utf8.RuneCountInString(name) > maxContactGroupNameLength {
+		return &Errors{ContactGroup: &ContactGroupError{
+			Name: []string{"Group name must be at most 64 characters."},
+		}}
+	}
+
+	return nil
+}
+
+// ContactGroups returns all of the contact groups owned by the given user
+// ordered by name.
+func ContactGroups(userID int64) ([]ContactGroup, *Errors) {
+	var groups []ContactGroup
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		log.Debugf("Querying contact groups of user %v.", userID)
+		rows, err := db.Query(
+			`SELECT CG.id, CG.name, Accounts.id, Accounts.display_name
+			 FROM ContactGroups AS CG
+			 LEFT JOIN ContactGroupMembers AS Members ON Members.group_id = CG.id
+			 LEFT JOIN Accounts ON Accounts.id = Members.account_id
+			 WHERE CG.account_id = ?
+			 ORDER BY CG.name ASC, CG.id ASC, Accounts.display_name ASC`,
+			userID)
+		if err != nil {
+			log.Warnf("Unable to query contact groups, %v.", err)
+			errors = &Errors{App: []string{"Unable to query groups at this time."}}
+			return
+		}
+
+		groups = rowsToContactGroups(rows)
+	})
+
+	return groups, errors
+}
+
+// ContactGroupByID returns a single contact group owned by the given user.
+func ContactGroupByID(userID, groupID int64) (*ContactGroup, *Errors) {
+	groups, errors := ContactGroups(userID)
+	if errors != nil {
+		return nil, errors
+	}
+
+	for i := range groups {
+		if groups[i].ID == groupID {
+			return &groups[i], nil
+		}
+	}
+	return nil, &Errors{App: []string{"No such group."}}
+}
+
+func rowsToContactGroups(rows *sql.Rows) []ContactGroup {
+	defer rows.Close()
+
+	groups := make([]ContactGroup, 0)
+	for rows.Next() {
+		var group ContactGroup
+		var memberID sql.NullInt64
+		var memberName sql.NullString
+		err := rows.Scan(&group.ID, &group.Name, &memberID, &memberName)
+		if err != nil {
+			log.Warnf("Unable to scan row, %v.", err.Error())
+			continue
+		}
+
+		// Rows are ordered by group, so a member either belongs to the last group
+		// seen or is the first member of a new group.
+		if len(groups) == 0 || groups[len(groups)-1].ID != group.ID {
+			groups = append(groups, group)
+		}
+
+		if memberID.Valid {
+			last := &groups[len(groups)-1]
+			last.Members = append(last.Members,
+				User{ID: memberID.Int64, DisplayName: memberName.String})
+		}
+	}
+	return groups
+}
+
+// CreateContactGroup creates a new contact group owned by the given user that
+// contains the members of the given group. Members only need their ID set.
+func CreateContactGroup(userID int64, group ContactGroup) (*ContactGroup, *Errors) {
+	errors := validateContactGroupName(group.Name)
+	if errors != nil {
+		return nil, errors
+	}
+
+	var groupID int64
+	db.WithTx(func(tx *sql.Tx) error {
+		log.Debugf("Creating contact group %#v for user %v.", group.Name, userID)
+		res, err := tx.Exec(
+			`INSERT INTO ContactGroups (account_id, name, created_at)
+			 VALUES (?, ?, NOW())`,
+			userID, group.Name)
+		if err != nil {
+			log.Warnf("Unable to create contact group, %v.", err)
+			errors = &Errors{App: []string{"Unable to create group at this time."}}
+			return err
+		}
+
+		groupID, err = res.LastInsertId()
+		if err != nil {
+			errors = &Errors{App: []string{"Unable to create group at this time."}}
+			return err
+		}
+
+		for _, member := range group.Members {
+			errors = addContactGroupMemberInTx(tx, userID, groupID, member.ID)
+			if errors != nil {
+				return errors
+			}
+		}
+
+		return nil
+	})
+
+	if errors != nil {
+		return nil, errors
+	}
+	return ContactGroupByID(userID, groupID)
+}
+
+// RenameContactGroup changes the name of a contact group owned by the given
+// user.
+func RenameContactGroup(userID, groupID int64, name string) *Errors {
+	errors := validateContactGroupName(name)
+	if errors != nil {
+		return errors
+	}
+
+	db.WithTx(func(tx *sql.Tx) error {
+		log.Debugf("Renaming contact group %v to %#v.", groupID, name)
+		_, err := tx.Exec(
+			"UPDATE ContactGroups SET name = ? WHERE id = ? AND account_id = ?",
+			name, groupID, userID)
+		if err != nil {
+			log.Warnf("Unable to rename contact group, %v.", err)
+			errors = &Errors{App: []string{"Unable to rename group at this time."}}
+			return err
+		}
+
+		// A rename to the same name affects no rows, so ownership has to be
+		// checked separately.
+		errors = checkContactGroupOwner(tx, userID, groupID)
+		if errors != nil {
+			return errors
+		}
+		return nil
+	})
+
+	return errors
+}
+
+// DeleteContactGroup deletes a contact group owned by the given user.
+func DeleteContactGroup(userID, groupID int64) *Errors {
+	var errors *Errors
+	db.WithTx(func(tx *sql.Tx) error {
+		errors = checkContactGroupOwner(tx, userID, groupID)
+		if errors != nil {
+			return errors
+		}
+
+		log.Debugf("Deleting contact group %v.", groupID)
+		_, err := tx.Exec(
+			"DELETE FROM ContactGroupMembers WHERE group_id = ?", groupID)
+		if err == nil {
+			_, err = tx.Exec("DELETE FROM Schedules WHERE group_id = ?", groupID)
+		}
+		if err == nil {
+			_, err = tx.Exec("DELETE FROM ContactGroups WHERE id = ?", groupID)
+		}
+		if err != nil {
+			log.Warnf("Unable to delete contact group, %v.", err)
+			errors = &Errors{App: []string{"Unable to delete group at this time."}}
+			return err
+		}
+
+		return nil
+	})
+
+	return errors
+}
+
+// AddContactGroupMember adds a player to a contact group owned by the given
+// user. Adding an existing member is not an error.
+func AddContactGroupMember(userID, groupID, memberID int64) *Errors {
+	var errors *Errors
+	db.WithTx(func(tx *sql.Tx) error {
+		errors = checkContactGroupOwner(tx, userID, groupID)
+		if errors != nil {
+			return errors
+		}
+
+		errors = addContactGroupMemberInTx(tx, userID, groupID, memberID)
+		if errors != nil {
+			return errors
+		}
+		return nil
+	})
+
+	return errors
+}
+
+// RemoveContactGroupMember removes a player from a contact group owned by the
+// given user.
+func RemoveContactGroupMember(userID, groupID, memberID int64) *Errors {
+	var errors *Errors
+	db.WithTx(func(tx *sql.Tx) error {
+		errors = checkContactGroupOwner(tx, userID, groupID)
+		if errors != nil {
+			return errors
+		}
+
+		log.Debugf("Removing member %v from contact group %v.", memberID, groupID)
+		_, err := tx.Exec(
+			"DELETE FROM ContactGroupMembers WHERE group_id = ? AND account_id = ?",
+			groupID, memberID)
+		if err != nil {
+			log.Warnf("Unable to remove contact group member, %v.", err)
+			errors = &Errors{App: []string{"Unable to update group at this time."}}
+			return err
+		}
+
+		return nil
+	})
+
+	return errors
+}
+
+func checkContactGroupOwner(tx *sql.Tx, userID, groupID int64) *Errors {
+	var owned bool
+	row := tx.QueryRow(
+		"SELECT COUNT(*) > 0 FROM ContactGroups WHERE id = ? AND account_id = ?",
+		groupID, userID)
+	err := row.Scan(&owned)
+	if err != nil || !owned {
+		log.Debugf("User %v does not own contact group %v.", userID, groupID)
+		return &Errors{App: []string{"No such group."}}
+	}
+	return nil
+}
+
+func addContactGroupMemberInTx(tx *sql.Tx, userID, groupID, memberID int64) *Errors {
+	if memberID == userID {
+		return &Errors{ContactGroup: &ContactGroupError{
+			Members: []string{"You are always included in your own groups."},
+		}}
+	}
+
+	log.Debugf("Adding member %v to contact group %v.", memberID, groupID)
+	var exists bool
+	row := tx.QueryRow("SELECT COUNT(*) > 0 FROM Accounts WHERE id = ?", memberID)
+	err := row.Scan(&exists)
+	if err != nil || !exists {
+		return &Errors{ContactGroup: &ContactGroupError{
+			Members: []string{"No such player id " + strconv.FormatInt(memberID, 10) + "."},
+		}}
+	}
+
+	_, err = tx.Exec(
+		"INSERT IGNORE INTO ContactGroupMembers (group_id, account_id) VALUES (?, ?)",
+		groupID, memberID)
+	if err != nil {
+		log.Warnf("Unable to add contact group member, %v.", err)
+		return &Errors{App: []string{"Unable to update group at this time."}}
+	}
+	return nil
+}
+
+// contactGroupMembers returns the members of a contact group owned by the
+// given user.
+func contactGroupMembers(tx *sql.Tx, userID, groupID int64) ([]User, *Errors) {
+	errors := checkContactGroupOwner(tx, userID, groupID)
+	if errors != nil {
+		return nil, errors
+	}
+
+	rows, err := tx.Query(
+		`SELECT Accounts.id, Accounts.display_name
+		 FROM ContactGroupMembers AS Members
+		 INNER JOIN Accounts ON Accounts.id = Members.account_id
+		 WHERE Members.group_id = ?
+		 ORDER BY Accounts.display_name ASC`,
+		groupID)
+	if err != nil {
+		log.Warnf("Unable to query contact group members, %v.", err)
+		return nil, &Errors{App: []string{"Unable to query groups at this time."}}
+	}
+
+	return rowsToUsers(rows), nil
+}
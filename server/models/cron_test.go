@@ -0,0 +1,171 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		field    string
+		min, max int
+		want     []int
+	}{
+		{"*", 0, 6, []int{0, 1, 2, 3, 4, 5, 6}},
+		{"5", 0, 59, []int{5}},
+		{"*/15", 0, 59, []int{0, 15, 30, 45}},
+		{"1-5", 0, 7, []int{1, 2, 3, 4, 5}},
+		{"1,3,5", 0, 7, []int{1, 3, 5}},
+		{"10-30/10", 0, 59, []int{10, 20, 30}},
+		{"50/5", 0, 59, []int{50, 55}},
+		{"1-2,20-22", 1, 31, []int{1, 2, 20, 21, 22}},
+	}
+
+	for _, test := range tests {
+		got, err := parseCronField(test.field, test.min, test.max)
+		if err != nil {
+			t.Errorf("parseCronField(%#v) failed, %v", test.field, err)
+			continue
+		}
+		var want uint64
+		for _, v := range test.want {
+			want |= 1 << uint(v)
+		}
+		if got != want {
+			t.Errorf("parseCronField(%#v) = %b, want %b", test.field, got, want)
+		}
+	}
+}
+
+func TestParseCronRejectsInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/-1 * * * *",
+		"1- * * * *",
+		"a * * * *",
+		"1,,2 * * * *",
+	}
+
+	for _, expr := range tests {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%#v) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		name  string
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		{"every 15 minutes", "*/15 * * * *",
+			date(2017, time.March, 1, 10, 7), date(2017, time.March, 1, 10, 15)},
+		{"strictly after", "*/15 * * * *",
+			date(2017, time.March, 1, 10, 15), date(2017, time.March, 1, 10, 30)},
+		{"hour list", "0 8,20 * * *",
+			date(2017, time.March, 1, 9, 0), date(2017, time.March, 1, 20, 0)},
+		{"weekdays", "0 9 * * 1-5",
+			date(2017, time.March, 3, 10, 0), date(2017, time.March, 6, 9, 0)},
+		{"Sunday as 0", "0 12 * * 0",
+			date(2017, time.March, 1, 0, 0), date(2017, time.March, 5, 12, 0)},
+		{"Sunday as 7", "0 12 * * 7",
+			date(2017, time.March, 1, 0, 0), date(2017, time.March, 5, 12, 0)},
+		{"range with step", "0 10-14/2 * * *",
+			date(2017, time.March, 1, 11, 0), date(2017, time.March, 1, 12, 0)},
+		{"day of week matches", "0 0 13 * 5",
+			date(2017, time.January, 1, 0, 0), date(2017, time.January, 6, 0, 0)},
+		{"day of month matches", "0 0 15 * 5",
+			date(2017, time.January, 13, 0, 0), date(2017, time.January, 15, 0, 0)},
+		{"month rollover", "30 23 31 * *",
+			date(2017, time.January, 31, 23, 30), date(2017, time.March, 31, 23, 30)},
+		{"year rollover", "0 0 1 1 *",
+			date(2017, time.June, 1, 0, 0), date(2018, time.January, 1, 0, 0)},
+		{"leap day", "0 0 29 2 *",
+			date(2017, time.March, 1, 0, 0), date(2020, time.February, 29, 0, 0)},
+	}
+
+	for _, test := range tests {
+		c, err := parseCron(test.expr)
+		if err != nil {
+			t.Errorf("%s: parseCron(%#v) failed, %v", test.name, test.expr, err)
+			continue
+		}
+		got, ok := c.next(test.after)
+		if !ok || !got.Equal(test.want) {
+			t.Errorf("%s: next(%v) = %v, %v, want %v",
+				test.name, test.after, got, ok, test.want)
+		}
+	}
+}
+
+func TestCronNextNever(t *testing.T) {
+	c, err := parseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("parseCron failed, %v", err)
+	}
+	if got, ok := c.next(date(2017, time.January, 1, 0, 0)); ok {
+		t.Errorf("next = %v, want no run", got)
+	}
+}
+
+func TestValidateScheduleWeekly(t *testing.T) {
+	schedule := &Schedule{
+		GroupID: 1,
+		Weekly:  &Weekly{Weekday: 3, Hour: 18, Minute: 30},
+	}
+	if _, errors := validateSchedule(schedule); errors != nil {
+		t.Fatalf("validateSchedule failed, %v", errors.Error())
+	}
+	if schedule.Cron != "30 18 * * 3" {
+		t.Errorf("Cron = %#v, want %#v", schedule.Cron, "30 18 * * 3")
+	}
+	if schedule.Weekly != nil {
+		t.Errorf("Weekly = %v, want nil", schedule.Weekly)
+	}
+
+	invalid := []struct {
+		schedule   *Schedule
+		cron       bool
+		weeklyErrs int
+	}{
+		{&Schedule{GroupID: 1, Weekly: &Weekly{Weekday: 3, Hour: 24}}, false, 1},
+		{&Schedule{GroupID: 1, Weekly: &Weekly{Weekday: 8}}, false, 1},
+		{&Schedule{GroupID: 1, Weekly: &Weekly{Weekday: -1, Hour: -1, Minute: 60}},
+			false, 3},
+		{&Schedule{GroupID: 1, Cron: "0 0 * * *", Weekly: &Weekly{Weekday: 3}},
+			true, 0},
+	}
+	for _, test := range invalid {
+		weekly := *test.schedule.Weekly
+		_, errors := validateSchedule(test.schedule)
+		if errors == nil || errors.Schedule == nil {
+			t.Errorf("validateSchedule(%v) succeeded, want an error", weekly)
+			continue
+		}
+		if got := errors.Schedule.Cron != nil; got != test.cron {
+			t.Errorf("validateSchedule(%v) cron error = %v, want %v",
+				weekly, errors.Schedule.Cron, test.cron)
+		}
+		if got := len(errors.Schedule.Weekly); got != test.weeklyErrs {
+			t.Errorf("validateSchedule(%v) weekly errors = %v, want %v",
+				weekly, errors.Schedule.Weekly, test.weeklyErrs)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	minDisplayNameLength = 2
+	maxDisplayNameLength = 32
+)
+
+// displayNameHistoryWindow is the SQL interval during which a display name
+// that has been given up by a rename still resolves to its previous owner, and
+// during which it cannot be claimed by anyone else.
+const displayNameHistoryWindow = "INTERVAL 30 DAY"
+
+// reservedDisplayNames contains the lower cased display names that may not be
+// registered by any player.
+var reservedDisplayNames = map[string]bool{
+	"admin":         true,
+	"administrator": true,
+	"moderator":     true,
+	"pifuxelck":     true,
+	"root":          true,
+	"support":       true,
+	"system":        true,
+}
+
+// normalizeDisplayName returns the canonical form of a display name. All
+// display names are stored and compared in Unicode normalization form C so
+// that visually identical names cannot be registered twice.
+func normalizeDisplayName(name string) string {
+	return strings.TrimSpace(norm.NFC.String(name))
+}
+
+func isDisplayNameRune(r rune) bool {
+	switch {
+	case unicode.IsLetter(r), unicode.IsDigit(r), unicode.IsMark(r):
+		return true
+	case r == ' ', r == '-', r == '_', r == '.', r == '\'':
+		return true
+	}
+	return false
+}
+
+// validateDisplayName checks that a display name is well formed and returns
+// its normalized form. This does not check for uniqueness, which requires
+// access to the database, see checkDisplayNameAvailable.
+func validateDisplayName(name string) (string, *UserError) {
+	name = normalizeDisplayName(name)
+
+	var errs []string
+	length := utf8.RuneCountInString(name)
+	if length == 0 {
+		return "", &UserError{DisplayName: []string{"Username must be non-empty."}}
+	}
+	if length < minDisplayNameLength {
+		errs = append(errs, "Display name must be at least 2 characters.")
+	}
+	if length > maxDisplayNameLength {
+		errs = append(errs, "Display name must be at most 32 characters.")
+	}
+
+	for _, r := range name {
+		if !isDisplayNameRune(r) {
+			errs = append(errs, "Display name may only contain letters, numbers, "+
+				"spaces and the characters - _ . '")
+			break
+		}
+	}
+
+	if strings.Contains(name, "  ") {
+		errs = append(errs, "Display name may not contain consecutive spaces.")
+	}
+
+	if reservedDisplayNames[strings.ToLower(name)] {
+		errs = append(errs, "Display name is reserved.")
+	}
+
+	if len(errs) > 0 {
+		return "", &UserError{DisplayName: errs}
+	}
+	return name, nil
+}
+
+// checkDisplayNameAvailable returns an error if the given normalized display
+// name is in use by, or was recently given up by, any account other than
+// userID. Names are compared case insensitively by the collation of the
+// display_name column.
+func checkDisplayNameAvailable(tx *sql.Tx, userID int64, name string) *UserError {
+	row := tx.QueryRow(
+		`SELECT COUNT(*) FROM (
+		    SELECT id AS account_id FROM Accounts
+		    WHERE display_name = ?
+		    UNION ALL
+		    SELECT account_id FROM DisplayNameHistory
+		    WHERE display_name = ?
+		      AND changed_at > NOW() - `+displayNameHistoryWindow+`
+		 ) AS Names
+		 WHERE account_id != ?`,
+		name, name, userID)
+
+	var count int
+	err := row.Scan(&count)
+	if err != nil {
+		log.Warnf("Unable to check availability of %#v, %v.", name, err)
+		return &UserError{DisplayName: []string{"Unable to check display name."}}
+	}
+
+	if count > 0 {
+		log.Debugf("Display name %#v is already taken.", name)
+		return &UserError{DisplayName: []string{"Display name already taken."}}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateDisplayName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		errs int
+	}{
+		{"alice", "alice", 0},
+		{"  Alice Smith ", "Alice Smith", 0},
+		{"Jo", "Jo", 0},
+		{"O'Brien-Smith_2.0", "O'Brien-Smith_2.0", 0},
+		{"Zoë", "Zoë", 0},
+		// A decomposed e with a combining diaeresis is stored composed.
+		{"Zoe\u0308", "Zo\u00eb", 0},
+		{"日本語", "日本語", 0},
+		{strings.Repeat("a", 32), strings.Repeat("a", 32), 0},
+
+		{"", "", 1},
+		{"   ", "", 1},
+		{"a", "", 1},
+		{strings.Repeat("a", 33), "", 1},
+		{"alice😀", "", 1},
+		{"alice@home", "", 1},
+		{"alice  smith", "", 1},
+		{"Admin", "", 1},
+		{"root", "", 1},
+		{"😀", "", 2},
+	}
+
+	for _, test := range tests {
+		got, userErr := validateDisplayName(test.name)
+		errs := 0
+		if userErr != nil {
+			errs = len(userErr.DisplayName)
+		}
+		if errs != test.errs {
+			t.Errorf("validateDisplayName(%#v) = %v, want %v errors",
+				test.name, userErr, test.errs)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("validateDisplayName(%#v) = %#v, want %#v",
+				test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package models
+
+import (
+	"database/sql"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/db"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/models/common"
+)
+
+// FriendRequest is a pending request for friendship between the authenticated
+// user and another user.
+type FriendRequest struct {
+	User      User  `json:"user"`
+	Incoming  bool  `json:"incoming,omitempty"`
+	CreatedAt int64 `json:"created_at,omitempty"`
+}
+
+// areFriendsClause is a SQL condition that holds if the account in the given
+// column and the user bound to both placeholders are accepted friends.
+func areFriendsClause(column string) string {
+	return `EXISTS (
+	            SELECT 1 FROM FriendRequests
+	            WHERE FriendRequests.accepted_at IS NOT NULL
+	              AND ((FriendRequests.sender_id = ? AND FriendRequests.recipient_id = ` + column + `)
+	               OR (FriendRequests.sender_id = ` + column + ` AND FriendRequests.recipient_id = ?))
+	        )`
+}
+
+// PendingFriendRequests returns the friend requests that the given user has
+// sent or received and that have not yet been accepted.
+func PendingFriendRequests(userID int64) ([]FriendRequest, *Errors) {
+	var requests []FriendRequest
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		log.Debugf("Querying pending friend requests of user %v.", userID)
+		rows, err := db.Query(
+			`SELECT
+			    Accounts.id,
+			    Accounts.display_name,
+			    FriendRequests.recipient_id = ?,
+			    UNIX_TIMESTAMP(FriendRequests.created_at)
+			 FROM FriendRequests
+			 INNER JOIN Accounts ON Accounts.id = IF(
+			    FriendRequests.sender_id = ?,
+			    FriendRequests.recipient_id,
+			    FriendRequests.sender_id)
+			 WHERE (FriendRequests.sender_id = ? OR FriendRequests.recipient_id = ?)
+			   AND FriendRequests.accepted_at IS NULL
+			 ORDER BY FriendRequests.created_at DESC`,
+			userID, userID, userID, userID)
+		if err != nil {
+			log.Warnf("Unable to query friend requests, %v.", err)
+			errors = &Errors{App: []string{"Unable to query friend requests at this time."}}
+			return
+		}
+		defer rows.Close()
+
+		requests = make([]FriendRequest, 0)
+		for rows.Next() {
+			var r FriendRequest
+			err := rows.Scan(&r.User.ID, &r.User.DisplayName, &r.Incoming, &r.CreatedAt)
+			if err != nil {
+				log.Warnf("Unable to scan row, %v.", err.Error())
+				continue
+			}
+			requests = append(requests, r)
+		}
+	})
+
+	return requests, errors
+}
+
+// Friends returns the accepted friends of the given user ordered by display
+// name.
+func Friends(userID int64) ([]User, *Errors) {
+	var users []User
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		log.Debugf("Querying friends of user %v.", userID)
+		rows, err := db.Query(
+			`SELECT Accounts.id, Accounts.display_name
+			 FROM Accounts
+			 WHERE `+areFriendsClause("Accounts.id")+`
+			 ORDER BY Accounts.display_name ASC`,
+			userID, userID)
+		if err != nil {
+			log.Warnf("Unable to query friends, %v.", err)
+			errors = &Errors{App: []string{"Unable to query friends at this time."}}
+			return
+		}
+
+		users = rowsToUsers(rows)
+	})
+
+	return users, errors
+}
+
+// SendFriendRequest sends a friend request from the user with ID userID to the
+// user with ID recipientID. If the recipient has already sent a request to the
+// user, then that request is accepted instead.
+func SendFriendRequest(userID, recipientID int64) *Errors {
+	if userID == recipientID {
+		return &Errors{App: []string{"You cannot befriend yourself."}}
+	}
+
+	var errors *Errors
+	generalError := []string{"Unable to send friend request at this time."}
+	db.WithTx(func(tx *sql.Tx) error {
+		var exists bool
+		row := tx.QueryRow(
+			`SELECT COUNT(*) > 0 FROM Accounts
+			 WHERE id = ? AND `+notBlockedClause("Accounts.id"),
+			recipientID, userID, userID)
+		err := row.Scan(&exists)
+		if err != nil || !exists {
+			log.Debugf("Unable to befriend unknown user %v.", recipientID)
+			errors = &Errors{App: []string{"No such user."}}
+			return err
+		}
+
+		res, err := tx.Exec(
+			`UPDATE FriendRequests SET accepted_at = NOW()
+			 WHERE sender_id = ? AND recipient_id = ? AND accepted_at IS NULL`,
+			recipientID, userID)
+		if err != nil {
+			log.Warnf("Unable to accept reciprocal friend request, %v.", err)
+			errors = &Errors{App: generalError}
+			return err
+		}
+
+		if i, _ := res.RowsAffected(); i > 0 {
+			log.Debugf("Accepted reciprocal friend request from %v.", recipientID)
+			errors = addFriendsToContactsInTx(tx, userID, recipientID)
+			if errors != nil {
+				return errors
+			}
+			return nil
+		}
+
+		log.Debugf("Sending friend request from %v to %v.", userID, recipientID)
+		_, err = tx.Exec(
+			`INSERT IGNORE INTO FriendRequests (sender_id, recipient_id, created_at)
+			 SELECT ?, ?, NOW() FROM DUAL
+			 WHERE NOT EXISTS (
+			    SELECT 1 FROM FriendRequests
+			    WHERE sender_id = ? AND recipient_id = ?
+			 )`,
+			userID, recipientID, recipientID, userID)
+		if err != nil {
+			log.Warnf("Unable to send friend request, %v.", err)
+			errors = &Errors{App: generalError}
+			return err
+		}
+
+		return nil
+	})
+
+	return errors
+}
+
+// AcceptFriendRequest accepts the pending friend request sent to the user with
+// ID userID by the user with ID senderID. Both users are added to each other's
+// contacts.
+func AcceptFriendRequest(userID, senderID int64) *Errors {
+	var errors *Errors
+	db.WithTx(func(tx *sql.Tx) error {
+		log.Debugf("User %v is accepting friend request from %v.", userID, senderID)
+		res, err := tx.Exec(
+			`UPDATE FriendRequests SET accepted_at = NOW()
+			 WHERE sender_id = ? AND recipient_id = ? AND accepted_at IS NULL`,
+			senderID, userID)
+		if err != nil {
+			log.Warnf("Unable to accept friend request, %v.", err)
+			errors = &Errors{App: []string{"Unable to accept friend request at this time."}}
+			return err
+		}
+
+		if i, _ := res.RowsAffected(); i <= 0 {
+			errors = &Errors{App: []string{"No such friend request."}}
+			return errors
+		}
+
+		errors = addFriendsToContactsInTx(tx, userID, senderID)
+		if errors != nil {
+			return errors
+		}
+		return nil
+	})
+
+	return errors
+}
+
+// DeclineFriendRequest deletes the pending friend request sent to the user with
+// ID userID by the user with ID senderID.
+func DeclineFriendRequest(userID, senderID int64) *Errors {
+	log.Debugf("User %v is declining friend request from %v.", userID, senderID)
+	return deletePendingFriendRequest(senderID, userID)
+}
+
+// CancelFriendRequest deletes the pending friend request sent by the user with
+// ID userID to the user with ID recipientID.
+func CancelFriendRequest(userID, recipientID int64) *Errors {
+	log.Debugf("User %v is cancelling friend request to %v.", userID, recipientID)
+	return deletePendingFriendRequest(userID, recipientID)
+}
+
+func deletePendingFriendRequest(senderID, recipientID int64) *Errors {
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		res, err := db.Exec(
+			`DELETE FROM FriendRequests
+			 WHERE sender_id = ? AND recipient_id = ? AND accepted_at IS NULL`,
+			senderID, recipientID)
+		if err != nil {
+			log.Warnf("Unable to delete friend request, %v.", err)
+			errors = &Errors{App: []string{"Unable to update friend request at this time."}}
+			return
+		}
+
+		if i, _ := res.RowsAffected(); i <= 0 {
+			errors = &Errors{App: []string{"No such friend request."}}
+		}
+	})
+
+	return errors
+}
+
+// RemoveFriend ends the friendship between the users with IDs userID and
+// friendID. Contacts are left untouched.
+func RemoveFriend(userID, friendID int64) *Errors {
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		log.Debugf("User %v is removing friend %v.", userID, friendID)
+		_, err := db.Exec(
+			`DELETE FROM FriendRequests
+			 WHERE (sender_id = ? AND recipient_id = ?)
+			    OR (sender_id = ? AND recipient_id = ?)`,
+			userID, friendID, friendID, userID)
+		if err != nil {
+			log.Warnf("Unable to remove friend, %v.", err)
+			errors = &Errors{App: []string{"Unable to remove friend at this time."}}
+		}
+	})
+
+	return errors
+}
+
+func addFriendsToContactsInTx(tx *sql.Tx, a, b int64) *Errors {
+	_, err := tx.Exec(
+		`INSERT IGNORE INTO Contacts (account_id, contact_id, created_at)
+		 VALUES (?, ?, NOW()), (?, ?, NOW())`,
+		a, b, b, a)
+	if err != nil {
+		log.Warnf("Unable to add friends to contacts, %v.", err)
+		return &Errors{App: []string{"Unable to accept friend request at this time."}}
+	}
+	return nil
+}
+
+// friendsOnlyPlayers returns the set of players that only allow friends to
+// include them in games and that are not friends of the given user.
+func friendsOnlyPlayers(tx *sql.Tx, userID int64, players []User) (map[int64]bool, error) {
+	result := make(map[int64]bool)
+	if len(players) == 0 {
+		return result, nil
+	}
+
+	args := make([]interface{}, 0, len(players)+2)
+	for _, player := range players {
+		args = append(args, player.ID)
+	}
+	args = append(args, userID, userID)
+
+	rows, err := tx.Query(
+		`SELECT id FROM Accounts
+		 WHERE id IN (`+common.Placeholders(len(players))+`)
+		   AND friends_only = 1
+		   AND NOT `+areFriendsClause("Accounts.id"),
+		args...)
+	if err != nil {
+		log.Warnf("Unable to check friends only players, %v.", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		result[id] = true
+	}
+	return result, nil
+}
@@ -3,7 +3,8 @@ package models
 import (
 	"database/sql"
 	"encoding/json"
-	"math/rand"
+	"strconv"
+	"time"
 
 	"github.com/GreatestGuys/pifuxelck-server-go/server/db"
 	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
@@ -15,86 +16,227 @@ type Game struct {
 	Turns         []*Turn `json:"turns,omitempty"`
 	CompletedAt   int64   `json:"completed_at,omitempty"`
 	CompletedAtID string  `json:"completed_at_id,omitempty"`
+	TurnDuration  int64   `json:"turn_duration,omitempty"`
+
+	// Reactions contains the reactions to all of the turns of the game, and
+	// IsFavorite is true if the requesting user has favorited the game.
+	Reactions  []Reaction `json:"reactions,omitempty"`
+	IsFavorite bool       `json:"is_favorite,omitempty"`
+
+	// VotingEndsAt is the time after which votes for the game's best drawing
+	// and best label are no longer accepted.
+	VotingEndsAt int64 `json:"voting_ends_at,omitempty"`
+
+	// CircleID is set on the games of a circle, see linkCircleInTx. In the
+	// history a circle is a single game whose Chains contain its games.
+	CircleID int64  `json:"circle_id,omitempty"`
+	Chains   []Game `json:"chains,omitempty"`
 }
 
 type NewGame struct {
-	Label   string   `json:"label,omitempty"`
+	Label string `json:"label,omitempty"`
+
+	// Players contains the other players of the game, each given as either an
+	// account ID or a display name.
 	Players []string `json:"players,omitempty"`
+
+	// GroupID is the optional ID of one of the creator's contact groups whose
+	// members are added to Players.
+	GroupID int64 `json:"group_id,omitempty"`
+
+	// TurnDuration is the number of seconds each player has to take their turn
+	// before being skipped. If zero, the server's default is used.
+	TurnDuration int64 `json:"turn_duration,omitempty"`
+
+	// RandomPrompt asks the server to pick the starting label from its prompt
+	// library instead of using Label. The picked prompt is returned to the
+	// creator unless HidePrompt is set, in which case they only see it once the
+	// game has completed.
+	RandomPrompt *PromptFilter `json:"random_prompt,omitempty"`
+	HidePrompt   bool          `json:"hide_prompt,omitempty"`
+
+	// Mode is the identifier of the game mode, see GameMode. If empty, the
+	// default mode is used.
+	Mode string `json:"mode,omitempty"`
+
+	// Circle asks for GameModeCircle. It predates Mode and is still accepted
+	// from older clients.
+	Circle bool `json:"circle,omitempty"`
+
+	// ordered is set by server side callers, such as RematchGame, that need the
+	// players to take their turns in the order given rather than shuffled.
+	ordered bool
+
+	// pendingLabel is set by server side callers, such as the scheduler, that
+	// create a game on behalf of a creator who writes the starting label from
+	// their inbox instead.
+	pendingLabel bool
 }
 
 type NewGameError struct {
-	Label   []string `json:"label,omitempty"`
-	Players []string `json:"players,omitempty"`
+	Label        []string `json:"label,omitempty"`
+	Mode         []string `json:"mode,omitempty"`
+	Players      []string `json:"players,omitempty"`
+	RandomPrompt []string `json:"random_prompt,omitempty"`
+	TurnDuration []string `json:"turn_duration,omitempty"`
 }
 
 func (e NewGameError) Error() string {
 	return common.ModelErrorHelper(e)
 }
 
+// CreatedGame describes a game that was just created.
+type CreatedGame struct {
+	GameID int64 `json:"game_id,omitempty"`
+
+	// Prompt is the starting label picked by the server, if one was requested
+	// and it is not hidden from the creator.
+	Prompt *Prompt `json:"prompt,omitempty"`
+
+	// AwayPlayers contains the requested players that were left out of the
+	// game because they are away.
+	AwayPlayers []User `json:"away_players,omitempty"`
+}
+
 // CreateGame creates a new game where the first turn is a label submitted by
 // the given user ID, and the remaining turns are alternating drawing and labels
-// with the players corresponding to the entries in the NewGame struct.
-func CreateGame(userID int64, newGame NewGame) *Errors {
-	if newGame.Label == "" {
+// with the players corresponding to the entries in the NewGame struct. The
+// layout of the turns is decided by the game mode selected by NewGame.Mode.
+func CreateGame(userID int64, newGame NewGame) (*CreatedGame, *Errors) {
+	if newGame.Label == "" && newGame.RandomPrompt == nil && !newGame.pendingLabel {
 		log.Debugf("Failed to create game due to lack of label.")
-		return &Errors{NewGame: &NewGameError{
+		return nil, &Errors{NewGame: &NewGameError{
 			Label: []string{"A label is required to start a game."},
 		}}
 	}
 
-	if len(newGame.Players) <= 0 {
+	if newGame.Label != "" && newGame.RandomPrompt != nil {
+		log.Debugf("Failed to create game due to both label and random prompt.")
+		return nil, &Errors{NewGame: &NewGameError{
+			Label: []string{"A label and a random prompt cannot both be given."},
+		}}
+	}
+
+	if len(newGame.Players) <= 0 && newGame.GroupID == 0 {
 		log.Debugf("Failed to create game due to lack of players.")
-		return &Errors{NewGame: &NewGameError{
+		return nil, &Errors{NewGame: &NewGameError{
 			Players: []string{"At least one other player is required."},
 		}}
 	}
 
+	if newGame.Circle {
+		if newGame.Mode != "" && newGame.Mode != GameModeCircle {
+			log.Debugf("Failed to create game due to circle with mode %#v.",
+				newGame.Mode)
+			return nil, &Errors{NewGame: &NewGameError{
+				Mode: []string{"A circle game cannot have another mode."},
+			}}
+		}
+		newGame.Mode = GameModeCircle
+	}
+
+	mode, ok := gameModeByID(newGame.Mode)
+	if !ok {
+		log.Debugf("Failed to create game due to unknown mode %#v.", newGame.Mode)
+		return nil, &Errors{NewGame: &NewGameError{
+			Mode: []string{"No such game mode."},
+		}}
+	}
+
+	turnDuration, errors := newGameTurnDuration(newGame)
+	if errors != nil {
+		return nil, errors
+	}
+
+	created := &CreatedGame{}
 	genericError := []string{"Unable to create a new game at this time."}
-	var errors *Errors
 	db.WithTx(func(tx *sql.Tx) error {
-		res, _ := tx.Exec(
-			`INSERT INTO Games (completed_at_id , next_expiration)
-			 VALUES (NULL, NOW() + INTERVAL 2 DAY)`)
+		var players []User
+		players, errors = resolveNewGamePlayers(tx, userID, newGame)
+		if errors != nil {
+			return errors
+		}
 
-		gameID, err := res.LastInsertId()
+		// Players that are away are left out rather than stalling the game, and
+		// the creator is told who they are.
+		away, err := awayPlayers(tx, players)
 		if err != nil {
 			errors = &Errors{App: genericError}
 			return err
 		}
+		present := make([]User, 0, len(players))
+		for _, player := range players {
+			if away[player.ID] {
+				created.AwayPlayers = append(created.AwayPlayers, player)
+			} else {
+				present = append(present, player)
+			}
+		}
+		if len(present) == 0 {
+			errors = &Errors{NewGame: &NewGameError{
+				Players: []string{"Every other player is away."},
+			}}
+			return errors
+		}
+		players = present
 
-		// Insert the first turn into the database. This turn will correspond to
-		// the label in the new game request and will be logged as being performed
-		// by the user that is creating the game.
-		_, err = tx.Exec(
-			`INSERT INTO Turns
-				 (account_id, game_id, is_complete, is_drawing, label, drawing)
-				 VALUES (?, ?, 1, 0, ?, '')`,
-			userID, gameID, newGame.Label)
-		if err != nil {
-			errors = &Errors{App: genericError}
-			return err
+		label := newGame.Label
+		if newGame.RandomPrompt != nil {
+			prompt, problems, err := randomPromptInTx(tx, *newGame.RandomPrompt)
+			if err != nil {
+				errors = &Errors{App: genericError}
+				return err
+			}
+			if problems != nil {
+				errors = &Errors{NewGame: &NewGameError{RandomPrompt: problems}}
+				return errors
+			}
+
+			log.Debugf("Picked prompt %v for new game.", prompt.ID)
+			label = prompt.Text
+			if !newGame.HidePrompt {
+				created.Prompt = prompt
+			}
+		}
+
+		// The creator always goes first, followed by each player in the Players
+		// list of newGame in the order chosen by the mode, which avoids repeating
+		// the order of the creator's recent games.
+		var playerIDs []int64
+		for _, player := range players {
+			playerIDs = append(playerIDs, player.ID)
+		}
+		if !newGame.ordered {
+			history, err := recentOrderHistory(tx, userID)
+			if err != nil {
+				errors = &Errors{App: genericError}
+				return err
+			}
+			playerIDs = mode.Order(orderShuffler, history, userID, playerIDs)
 		}
+		playerIDs = append([]int64{userID}, playerIDs...)
+
+		// Only the creator's label is known up front, any other game that the
+		// mode creates waits for its first player to write a starting label.
+		var gameIDs []int64
+		for i, chain := range mode.Chains(playerIDs) {
+			chainLabel := ""
+			if i == 0 {
+				chainLabel = label
+			}
 
-		// Create a turn entry for each player (in a random order) in the Players
-		// list of newGame, alternating drawing and label turns.
-		order := rand.Perm(len(newGame.Players))
-		for i, v := range order {
-			playerID := newGame.Players[v]
-			isDrawing := i%2 == 0
-			_, err := tx.Exec(
-				`INSERT INTO Turns
-				 ( account_id
-				 , game_id
-				 , is_complete
-				 , is_drawing
-				 , label
-				 , drawing
-				 ) VALUES (?, ?, 0, ?, '', '')`,
-				playerID, gameID, isDrawing)
+			gameID, err := insertChainInTx(tx, mode, chain, chainLabel, turnDuration)
 			if err != nil {
-				errors = &Errors{NewGame: &NewGameError{
-					Players: []string{"No such player id " + playerID + "."},
-				}}
+				errors = &Errors{App: genericError}
+				return err
+			}
+			gameIDs = append(gameIDs, gameID)
+		}
+		created.GameID = gameIDs[0]
+
+		if len(gameIDs) > 1 {
+			if err := linkCircleInTx(tx, gameIDs); err != nil {
+				errors = &Errors{App: genericError}
 				return err
 			}
 		}
@@ -102,7 +244,75 @@ func CreateGame(userID int64, newGame NewGame) *Errors {
 		return nil
 	})
 
-	return errors
+	if errors != nil {
+		return nil, errors
+	}
+	return created, nil
+}
+
+// insertChainInTx inserts a game in which the given players take turns in
+// order, with the type of each turn decided by the game mode. The first
+// player's turn is the starting label, which is already complete if label is
+// non-empty and is otherwise left for them to write.
+func insertChainInTx(tx *sql.Tx, mode GameMode, playerIDs []int64, label string, turnDuration int64) (int64, error) {
+	res, err := tx.Exec(
+		`INSERT INTO Games
+		 (completed_at_id, created_at, next_expiration, turn_duration, mode)
+		 VALUES (NULL, NOW(), NOW() + INTERVAL ? SECOND, ?, ?)`,
+		turnDuration, turnDuration, mode.ID())
+	if err != nil {
+		return 0, err
+	}
+
+	gameID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, playerID := range playerIDs {
+		isComplete := i == 0 && label != ""
+		isDrawing := mode.IsDrawing(i)
+		turnLabel := ""
+		if i == 0 {
+			turnLabel = label
+		}
+		_, err := tx.Exec(
+			`INSERT INTO Turns
+			 ( account_id
+			 , game_id
+			 , is_complete
+			 , is_drawing
+			 , label
+			 , drawing
+			 ) VALUES (?, ?, ?, ?, ?, '')`,
+			playerID, gameID, isComplete, isDrawing, turnLabel)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return gameID, nil
+}
+
+// newGameTurnDuration returns the turn duration in seconds requested by a new
+// game, or an error if it is outside of the configured bounds.
+func newGameTurnDuration(newGame NewGame) (int64, *Errors) {
+	if newGame.TurnDuration == 0 {
+		return int64(config.DefaultTurnDuration / time.Second), nil
+	}
+
+	duration := time.Duration(newGame.TurnDuration) * time.Second
+	if duration < config.MinTurnDuration || duration > config.MaxTurnDuration {
+		log.Debugf("Failed to create game due to turn duration %v.", duration)
+		return 0, &Errors{NewGame: &NewGameError{
+			TurnDuration: []string{
+				"Turn duration must be between " + config.MinTurnDuration.String() +
+					" and " + config.MaxTurnDuration.String() + ".",
+			},
+		}}
+	}
+
+	return newGame.TurnDuration, nil
 }
 
 // UpdateGameCompletedAtTime takes a game ID and updates the completion time if
@@ -122,107 +332,345 @@ func UpdateGameCompletedAtTime(gameID int64) *Errors {
 
 func updateGameCompletedAtTimeInTx(gameID int64) func(*sql.Tx) error {
 	return func(tx *sql.Tx) error {
-		// This query is a conditional insert that will create an entry in the
-		// GamesCompletedAt table if and only if the game with id gameID is
-		// complete AND there is not already an entry in GamesCompletedAt for this
-		// game.
-		res, err := tx.Exec(
-			`INSERT INTO GamesCompletedAt (completed_at)
-			 (
-					SELECT NOW()
-					FROM Games
-					WHERE (
-							SELECT completed_at_id
-							FROM Games
-							WHERE id = ?) IS NULL
-						AND 1 = (
-								 SELECT SUM(is_complete) = COUNT(*)
-								 FROM Turns
-								 WHERE game_id = ?)
-					LIMIT 1
-			 )`,
-			gameID, gameID)
+		// The games of a circle complete together, so every turn of every game
+		// in the set is considered. For any other game the set is just the game.
+		setID, err := gameSetID(tx, gameID)
+		if err != nil {
+			log.Warnf("Unable to look up game %v, %v.", gameID, err)
+			return err
+		}
+
+		mode, err := gameModeOf(tx, gameID)
+		if err != nil {
+			return err
+		}
+
+		// The games of the set are locked so that concurrent turns cannot both
+		// assign a completed at ID.
+		var pending int
+		row := tx.QueryRow(
+			`SELECT COUNT(*) FROM Games
+			 WHERE (id = ? OR circle_id = ?) AND completed_at_id IS NULL
+			 FOR UPDATE`,
+			setID, setID)
+		if err := row.Scan(&pending); err != nil {
+			log.Warnf("Unable to lock game %v, %v.", gameID, err)
+			return err
+		}
+		if pending == 0 {
+			return nil
+		}
+
+		var completed, total int
+		row = tx.QueryRow(
+			`SELECT IFNULL(SUM(Turns.is_complete), 0), COUNT(*)
+			 FROM Turns
+			 INNER JOIN Games ON Games.id = Turns.game_id
+			 WHERE Games.id = ? OR Games.circle_id = ?`,
+			setID, setID)
+		if err := row.Scan(&completed, &total); err != nil {
+			log.Warnf("Unable to count turns of game %v, %v.", gameID, err)
+			return err
+		}
+
+		// If the game is not over, then there is nothing to do.
+		if !mode.IsComplete(completed, total) {
+			return nil
+		}
+
+		res, err := tx.Exec("INSERT INTO GamesCompletedAt (completed_at) VALUES (NOW())")
 		if err != nil {
 			log.Warnf("Query to insert completed at id failed, %v.", err)
 			return err
 		}
 
-		// If there is not last inserted ID, then the game was not over. This is
-		// fine, just return nil as a success.
 		completedAtID, err := res.LastInsertId()
 		if err != nil {
-			return nil
+			log.Warnf("Unable to get completed at id, %v.", err)
+			return err
 		}
 
-		// If there IS a completed at id, then update the game to point to this new
-		// entry.
 		_, err = tx.Exec(
-			"UPDATE Games SET completed_at_id = ? WHERE id = ?",
-			completedAtID, gameID)
+			`UPDATE Games SET completed_at_id = ?
+			 WHERE (id = ? OR circle_id = ?) AND completed_at_id IS NULL`,
+			completedAtID, setID, setID)
 		return err
 	}
 }
 
-// ReapExpiredTurns removes turns from games where the expiration time has
-// passed. This method should be called periodically to ensure that games to not
-// hang on players who have uninstalled the app or otherwise stopped playing.
-func ReapExpiredTurns() *Errors {
-	log.Debugf("Reaping expired turns.")
+// removeTurnInTx deletes a pending turn from a game and, if the game's mode
+// flips turns on skip, flips the type of every pending turn after it so that
+// the remaining turns still alternate between drawings and labels. If the
+// removed turn was the current turn, then the expiration is reset for the next
+// player. This is also how ReapExpiredTurns removes expired turns.
+func removeTurnInTx(tx *sql.Tx, gameID, turnID int64) error {
+	mode, err := gameModeOf(tx, gameID)
+	if err != nil {
+		return err
+	}
 
-	err := db.WithTx(func(tx *sql.Tx) error {
-		// First delete turns from games where the expiration time is in the past.
-		res, err := tx.Exec(
-			`DELETE Turns FROM Turns
-			 INNER JOIN (
-			    SELECT
-			        game_id,
-			        MIN(id) as next_id
-			    FROM Turns
-			    WHERE is_complete = 0
-			    GROUP BY game_id
-			 ) AS NextTurn ON NextTurn.next_id = Turns.id
-			 INNER JOIN (
-			    SELECT id FROM Games
-			    WHERE next_expiration < NOW()
-			 ) AS Games ON Games.id = Turns.game_id
-			 WHERE Turns.id = NextTurn.next_id`)
+	var isCurrent bool
+	row := tx.QueryRow(
+		`SELECT ? = MIN(id) FROM Turns WHERE game_id = ? AND is_complete = 0`,
+		turnID, gameID)
+	err = row.Scan(&isCurrent)
+	if err != nil {
+		log.Warnf("Unable to find the current turn of game %v, %v.", gameID, err)
+		return err
+	}
+
+	_, err = tx.Exec(
+		"DELETE FROM Turns WHERE id = ? AND game_id = ? AND is_complete = 0",
+		turnID, gameID)
+	if err != nil {
+		log.Warnf("Unable to delete turn %v, %v.", turnID, err)
+		return err
+	}
+
+	if mode.FlipOnSkip() {
+		_, err = tx.Exec(
+			`UPDATE Turns SET is_drawing = NOT is_drawing
+			 WHERE game_id = ? AND is_complete = 0 AND id > ?`,
+			gameID, turnID)
 		if err != nil {
-			log.Warnf("Unable to delete expired turns, %v.", err)
+			log.Warnf("Unable to update remaining turns, %v.", err)
 			return err
 		}
+	}
 
-		expiredTurns, _ := res.RowsAffected()
-		log.Debugf("Expired %v turns.", expiredTurns)
+	if isCurrent {
+		_, err = tx.Exec(
+			`UPDATE Games
+			 SET next_expiration = NOW() + INTERVAL turn_duration SECOND
+			 WHERE id = ?`,
+			gameID)
+		if err != nil {
+			log.Warnf("Unable to update expiration time of game %v, %v.", gameID, err)
+			return err
+		}
+	}
 
-		// Next, update the remaining turns
-		res, err = tx.Exec(
-			`UPDATE Turns
-			 INNER JOIN (
-					SELECT id FROM Games
-					WHERE next_expiration < NOW() AND completed_at_id IS NULL
-			 ) AS Games ON Games.id = Turns.game_id
-			 SET is_drawing = NOT is_drawing
-			 WHERE is_complete = 0`)
+	return updateGameCompletedAtTimeInTx(gameID)(tx)
+}
+
+// LeaveGame removes all of the given user's pending turns from a game that has
+// not yet completed. Leaving any game of a circle leaves the whole circle.
+func LeaveGame(userID, gameID int64) *Errors {
+	log.Debugf("User %v is leaving game %v.", userID, gameID)
+
+	var errors *Errors
+	errMsg := []string{"Unable to leave the game at this time."}
+	db.WithTx(func(tx *sql.Tx) error {
+		setID, err := gameSetID(tx, gameID)
 		if err != nil {
-			log.Warnf("Unable to update remaining turns, %v.", err)
+			errors = &Errors{App: []string{"You have no pending turn in this game."}}
 			return err
 		}
 
-		updatedTurns, _ := res.RowsAffected()
-		log.Debugf("%v turns updated to reflect new turn order.", updatedTurns)
+		rows, err := tx.Query(
+			`SELECT Turns.id, Turns.game_id FROM Turns
+			 INNER JOIN Games ON Games.id = Turns.game_id
+			 WHERE (Games.id = ? OR Games.circle_id = ?)
+			   AND Turns.account_id = ?
+			   AND Turns.is_complete = 0
+			   AND Games.completed_at_id IS NULL
+			 ORDER BY Turns.id DESC`,
+			setID, setID, userID)
+		if err != nil {
+			log.Warnf("Unable to query pending turns, %v.", err)
+			errors = &Errors{App: errMsg}
+			return err
+		}
 
-		// Next, update the remaining turns
-		res, err = tx.Exec(
-			`UPDATE Games
-			 SET next_expiration = NOW() + INTERVAL 2 DAY
-			 WHERE next_expiration < NOW() AND completed_at_id IS NULL`)
+		var turnIDs, gameIDs []int64
+		for rows.Next() {
+			var id, gameID int64
+			if err = rows.Scan(&id, &gameID); err != nil {
+				break
+			}
+			turnIDs = append(turnIDs, id)
+			gameIDs = append(gameIDs, gameID)
+		}
+		rows.Close()
+		if err != nil {
+			errors = &Errors{App: errMsg}
+			return err
+		}
+
+		if len(turnIDs) == 0 {
+			errors = &Errors{App: []string{"You have no pending turn in this game."}}
+			return errors
+		}
+
+		// Turns are removed last to first so that each removal only flips the
+		// turns that follow it.
+		for i, turnID := range turnIDs {
+			if err = removeTurnInTx(tx, gameIDs[i], turnID); err != nil {
+				errors = &Errors{App: errMsg}
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return errors
+}
+
+// CancelGame deletes a game. Only the creator of a game can cancel it, and only
+// while no other player has taken a turn. Cancelling any game of a circle
+// cancels the whole circle.
+func CancelGame(userID, gameID int64) *Errors {
+	log.Debugf("User %v is cancelling game %v.", userID, gameID)
+
+	var errors *Errors
+	errMsg := []string{"Unable to cancel the game at this time."}
+	db.WithTx(func(tx *sql.Tx) error {
+		setID, err := gameSetID(tx, gameID)
+		if err != nil {
+			log.Debugf("User %v cannot cancel game %v, %v.", userID, gameID, err)
+			errors = &Errors{App: []string{"No such game."}}
+			return err
+		}
+
+		// Lock the games of the set so that no turn can be completed between
+		// counting the completed turns and deleting the games. The count is a
+		// locking read as well, so it sees turns committed since this
+		// transaction began.
+		var pending int
+		row := tx.QueryRow(
+			`SELECT COUNT(*) FROM Games
+			 WHERE (id = ? OR circle_id = ?) AND completed_at_id IS NULL
+			 FOR UPDATE`,
+			setID, setID)
+		err = row.Scan(&pending)
+		if err != nil || pending == 0 {
+			log.Debugf("User %v cannot cancel game %v, %v.", userID, gameID, err)
+			errors = &Errors{App: []string{"No such game."}}
+			return errors
+		}
+
+		// The first turn of the set belongs to the creator, since the creator's
+		// game of a circle is inserted first.
+		var creatorID int64
+		row = tx.QueryRow(
+			`SELECT Turns.account_id
+			 FROM Turns
+			 INNER JOIN Games ON Games.id = Turns.game_id
+			 WHERE Games.id = ? OR Games.circle_id = ?
+			 ORDER BY Turns.id ASC
+			 LIMIT 1`,
+			setID, setID)
+		err = row.Scan(&creatorID)
+		if err != nil || creatorID != userID {
+			log.Debugf("User %v cannot cancel game %v, %v.", userID, gameID, err)
+			errors = &Errors{App: []string{"No such game."}}
+			return errors
+		}
+
+		var completedTurns int
+		row = tx.QueryRow(
+			`SELECT COUNT(*)
+			 FROM Turns
+			 INNER JOIN Games ON Games.id = Turns.game_id
+			 WHERE (Games.id = ? OR Games.circle_id = ?) AND Turns.is_complete = 1
+			 FOR UPDATE`,
+			setID, setID)
+		err = row.Scan(&completedTurns)
+		if err != nil {
+			log.Warnf("Unable to count the turns of game %v, %v.", gameID, err)
+			errors = &Errors{App: errMsg}
+			return err
+		}
+
+		if completedTurns > 1 {
+			errors = &Errors{App: []string{
+				"A game cannot be cancelled once another player has taken a turn."}}
+			return errors
+		}
+
+		_, err = tx.Exec(
+			`DELETE Turns FROM Turns
+			 INNER JOIN Games ON Games.id = Turns.game_id
+			 WHERE Games.id = ? OR Games.circle_id = ?`,
+			setID, setID)
+		if err == nil {
+			_, err = tx.Exec(
+				"DELETE FROM Games WHERE id = ? OR circle_id = ?", setID, setID)
+		}
 		if err != nil {
-			log.Warnf("Unable to update expiration time for affected games, %v.", err)
+			log.Warnf("Unable to delete game %v, %v.", gameID, err)
+			errors = &Errors{App: errMsg}
 			return err
 		}
 
-		updatedGames, _ := res.RowsAffected()
-		log.Debugf("%v games updated to reflect new expiration time.", updatedGames)
+		return nil
+	})
+
+	return errors
+}
+
+// ReapExpiredTurns removes turns from games where the expiration time has
+// passed, or where the current player is away. This method should be called
+// periodically to ensure that games to not hang on players who have
+// uninstalled the app or otherwise stopped playing.
+func ReapExpiredTurns() *Errors {
+	log.Debugf("Reaping expired turns.")
+
+	err := db.WithTx(func(tx *sql.Tx) error {
+		// Remove the current turn of every game where the expiration time is in
+		// the past or where the current player is away. Removing a turn may hand
+		// the game to another player that is away, so this repeats until no turn
+		// is left to remove. Every pass removes at least one turn, so it ends.
+		expiredTurns := 0
+		for {
+			rows, err := tx.Query(
+				`SELECT Games.id, CurrentTurn.id
+				 FROM Games
+				 INNER JOIN (
+				    SELECT game_id, MIN(id) AS turn_id
+				    FROM Turns
+				    WHERE is_complete = 0
+				    GROUP BY game_id
+				 ) AS Pending ON Pending.game_id = Games.id
+				 INNER JOIN Turns AS CurrentTurn ON CurrentTurn.id = Pending.turn_id
+				 INNER JOIN Accounts ON Accounts.id = CurrentTurn.account_id
+				 WHERE Games.completed_at_id IS NULL
+				   AND (Games.next_expiration < NOW() OR Accounts.away_until > NOW())`)
+			if err != nil {
+				log.Warnf("Unable to find expired turns, %v.", err)
+				return err
+			}
+
+			var gameIDs, turnIDs []int64
+			for rows.Next() {
+				var gameID, turnID int64
+				err = rows.Scan(&gameID, &turnID)
+				if err != nil {
+					rows.Close()
+					return err
+				}
+				gameIDs = append(gameIDs, gameID)
+				turnIDs = append(turnIDs, turnID)
+			}
+			rows.Close()
+
+			if len(turnIDs) == 0 {
+				break
+			}
+
+			// Each turn is removed according to the rules of its game's mode. This
+			// also resets the expiration time of the game and assigns a completed
+			// at ID if the removed turn was the last one.
+			for i, turnID := range turnIDs {
+				err = removeTurnInTx(tx, gameIDs[i], turnID)
+				if err != nil {
+					return err
+				}
+			}
+			expiredTurns += len(turnIDs)
+		}
+		log.Debugf("Expired %v turns.", expiredTurns)
 
 		// Obtain a list of all games where all of the turns are marked as complete,
 		// but where the game does not have a completed at ID.
@@ -267,18 +715,6 @@ func ReapExpiredTurns() *Errors {
 			}
 		}
 
-		// TODO(will): Uncomment this once the database is free of all orphaned
-		// games.
-		//
-		// If the number of updated games does not equal the number of expired
-		// turns, then fail the transaction.
-		//		if updatedGames != expiredTurns {
-		//			log.Warnf(
-		//				"Reaping failed, number of games (%v) and turns (%v) affected differs.",
-		//				updatedGames, expiredTurns)
-		//			return errors.New("Inconsistent number of turns and games affected.")
-		//		}
-
 		return nil
 	})
 
@@ -288,19 +724,24 @@ func ReapExpiredTurns() *Errors {
 	return nil
 }
 
+// rowsToGames builds games from rows of turns. Games are returned in the order
+// in which their first turn appears in the rows.
 func rowsToGames(rows *sql.Rows) []Game {
 	gameIDToGame := make(map[int64]*Game)
+	order := make([]*Game, 0)
 
 	defer rows.Close()
 	for rows.Next() {
 		var gameID int64
 		var completedAtID string
 		var completedAt int64
+		var turnDuration int64
+		var circleID int64
 		var drawingJson string
 		turn := &Turn{}
 		err := rows.Scan(
-			&gameID, &completedAtID, &completedAt,
-			&turn.Player, &turn.IsDrawing, &drawingJson, &turn.Label)
+			&gameID, &completedAtID, &completedAt, &turnDuration, &circleID,
+			&turn.id, &turn.Player, &turn.IsDrawing, &drawingJson, &turn.Label)
 		if err != nil {
 			log.Warnf("Unable to scan row, %v.", err.Error())
 			continue
@@ -323,14 +764,17 @@ func rowsToGames(rows *sql.Rows) []Game {
 			game.ID = gameID
 			game.CompletedAtID = completedAtID
 			game.CompletedAt = completedAt
+			game.TurnDuration = turnDuration
+			game.CircleID = circleID
 			gameIDToGame[gameID] = game
+			order = append(order, game)
 		}
 
 		game.Turns = append(game.Turns, turn)
 	}
 
-	games := make([]Game, 0, len(gameIDToGame))
-	for _, game := range gameIDToGame {
+	games := make([]Game, 0, len(order))
+	for _, game := range order {
 		games = append(games, *game)
 	}
 
@@ -348,13 +792,16 @@ func GameByID(userID, gameID int64) (*Game, *Errors) {
 			    Games.id,
 			    Games.completed_at_id,
 			    UNIX_TIMESTAMP(GamesCompletedAt.completed_at),
+			    Games.turn_duration,
+			    IFNULL(Games.circle_id, 0),
+			    Turns.id,
 			    Accounts.display_name,
 			    Turns.is_drawing,
 			    Turns.drawing,
 			    Turns.label
 			 From Turns as Turns
 			 INNER JOIN (
-			    SELECT id, completed_at_id
+			    SELECT id, completed_at_id, turn_duration, circle_id
 			    FROM Games as Games
 			    INNER JOIN (
 			        SELECT game_id FROM Turns AS T WHERE T.account_id = ?
@@ -382,6 +829,9 @@ func GameByID(userID, gameID int64) (*Game, *Errors) {
 			errors = &Errors{App: errMsg}
 			return
 		}
+
+		attachReactions(db, userID, games)
+		attachVotes(db, userID, games)
 		game = &games[0]
 	})
 
@@ -391,32 +841,79 @@ func GameByID(userID, gameID int64) (*Game, *Errors) {
 	return game, nil
 }
 
-// CompletedGames returns a list of games that a given user has participated in
-// and that have been completed since the given completed at ID.
-func CompletedGames(userID, sinceID int64) ([]Game, *Errors) {
+// The bounds on the number of games returned by a single call to
+// CompletedGames.
+const (
+	DefaultHistoryLimit = 10
+	MaxHistoryLimit     = 50
+)
+
+// HistoryPage describes a page of completed games in terms of completed at IDs.
+// If BeforeID is set, then the page contains the games completed immediately
+// before it, newest first. Otherwise it contains the games completed
+// immediately after SinceID, oldest first. SinceID and BeforeID cannot both be
+// set.
+type HistoryPage struct {
+	SinceID  int64
+	BeforeID int64
+	Limit    int
+}
+
+// CompletedGames returns a page of games that a given user has participated in
+// and that have been completed. The games of a circle complete together and
+// count as a single game. The returned Meta contains the cursor to pass
+// as the next SinceID or BeforeID, whichever was used, and whether there are
+// more games beyond it.
+func CompletedGames(userID int64, page HistoryPage) ([]Game, *Meta, *Errors) {
+	if page.Limit <= 0 {
+		page.Limit = DefaultHistoryLimit
+	}
+	if page.Limit > MaxHistoryLimit {
+		return nil, nil, &Errors{App: []string{
+			"At most " + strconv.Itoa(MaxHistoryLimit) + " games can be requested."}}
+	}
+
+	if page.SinceID > 0 && page.BeforeID > 0 {
+		return nil, nil, &Errors{App: []string{
+			"Only one of since and before may be given."}}
+	}
+
+	condition, direction, cursor := "Games.completed_at_id > ?", "ASC", page.SinceID
+	if page.BeforeID > 0 {
+		condition, direction, cursor = "Games.completed_at_id < ?", "DESC", page.BeforeID
+	}
+
 	var games []Game
 	var errors *Errors
 	errMsg := []string{"Unable to query history at this time."}
 	db.WithDB(func(db *sql.DB) {
+		// One more game than the limit is fetched to determine if there are more.
 		rows, err := db.Query(
 			`SELECT
 			    Games.id,
 			    Games.completed_at_id,
 			    UNIX_TIMESTAMP(GamesCompletedAt.completed_at),
+			    Games.turn_duration,
+			    IFNULL(Games.circle_id, 0),
+			    Turns.id,
 			    Accounts.display_name,
 			    Turns.is_drawing,
 			    Turns.drawing,
 			    Turns.label
 			 From Turns as Turns
 			 INNER JOIN (
-			    SELECT id, completed_at_id
+			    SELECT id, completed_at_id, turn_duration, circle_id
 			    FROM Games as Games
 			    INNER JOIN (
-			        SELECT game_id FROM Turns AS T WHERE T.account_id = ?
-			    ) AS T ON T.game_id = Games.id
-			    WHERE Games.completed_at_id > ?
-			    ORDER BY completed_at_id ASC
-			    LIMIT 10
+			        SELECT DISTINCT Games.completed_at_id AS page_id
+			        FROM Games as Games
+			        INNER JOIN (
+			            SELECT DISTINCT game_id FROM Turns AS T WHERE T.account_id = ?
+			        ) AS T ON T.game_id = Games.id
+			        WHERE `+condition+`
+			        ORDER BY Games.completed_at_id `+direction+`
+			        LIMIT ?
+			    ) AS Page ON Page.page_id = Games.completed_at_id
 			 ) AS Games ON Turns.game_id = Games.id
 			 INNER JOIN (
 			    SELECT id, display_name
@@ -426,8 +923,8 @@ func CompletedGames(userID, sinceID int64) ([]Game, *Errors) {
 			    SELECT id, completed_at FROM GamesCompletedAt as GamesCompletedAt
 			 ) AS GamesCompletedAt ON GamesCompletedAt.id = Games.completed_at_id
 			 GROUP BY Turns.id
-			 ORDER BY Games.id ASC, Turns.id ASC`,
-			userID, sinceID)
+			 ORDER BY Games.completed_at_id `+direction+`, Turns.id ASC`,
+			userID, cursor, page.Limit+1)
 		if err != nil {
 			log.Warnf("Unable to look up completed games, %v", err)
 			errors = &Errors{App: errMsg}
@@ -435,10 +932,21 @@ func CompletedGames(userID, sinceID int64) ([]Game, *Errors) {
 		}
 
 		games = rowsToGames(rows)
+		attachReactions(db, userID, games)
+		attachVotes(db, userID, games)
+		games = groupCircles(games)
 	})
 
 	if errors != nil {
-		return nil, errors
+		return nil, nil, errors
+	}
+
+	meta := &Meta{HasMore: len(games) > page.Limit}
+	if meta.HasMore {
+		games = games[:page.Limit]
+	}
+	if len(games) > 0 {
+		meta.NextCursor = games[len(games)-1].CompletedAtID
 	}
-	return games, nil
+	return games, meta, nil
 }
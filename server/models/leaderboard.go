@@ -0,0 +1,140 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/db"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/models/common"
+)
+
+// MaxLeaderboardEntries is the number of players listed on a leaderboard.
+const MaxLeaderboardEntries = 50
+
+// Leaderboard ranks players by the points they earned during a season. Each
+// vote that a player's turn receives is worth one point, and counts towards
+// the season in which the game completed. Votes only count once voting on
+// their game has closed, so that the leaderboard does not reveal the tallies
+// that the game itself hides until then.
+type Leaderboard struct {
+	Season   int64              `json:"season"`
+	StartsAt int64              `json:"starts_at,omitempty"`
+	EndsAt   int64              `json:"ends_at,omitempty"`
+	GroupID  int64              `json:"group_id,omitempty"`
+	Entries  []LeaderboardEntry `json:"entries"`
+}
+
+// LeaderboardEntry is a single player's standing on a leaderboard. Players
+// with the same number of points share the same rank.
+type LeaderboardEntry struct {
+	Rank   int  `json:"rank"`
+	User   User `json:"user"`
+	Points int  `json:"points"`
+}
+
+// CurrentSeason returns the number of the season in progress. Seasons are
+// numbered from zero.
+func CurrentSeason() int64 {
+	elapsed := time.Since(config.SeasonStart)
+	if elapsed < 0 {
+		return 0
+	}
+	return int64(elapsed / config.SeasonLength)
+}
+
+// seasonBounds returns the start and end of a season.
+func seasonBounds(season int64) (time.Time, time.Time) {
+	start := config.SeasonStart.Add(time.Duration(season) * config.SeasonLength)
+	return start, start.Add(config.SeasonLength)
+}
+
+// GetLeaderboard returns the leaderboard of the given season. If groupID is
+// zero the leaderboard covers every player that the given user has not
+// blocked, and otherwise only the members of one of the user's contact groups
+// and the user themselves.
+func GetLeaderboard(userID, season, groupID int64) (*Leaderboard, *Errors) {
+	if season < 0 || season > CurrentSeason() {
+		return nil, &Errors{App: []string{"No such season."}}
+	}
+
+	start, end := seasonBounds(season)
+	leaderboard := &Leaderboard{
+		Season:   season,
+		StartsAt: start.Unix(),
+		EndsAt:   end.Unix(),
+		GroupID:  groupID,
+		Entries:  make([]LeaderboardEntry, 0),
+	}
+
+	var errors *Errors
+	errMsg := []string{"Unable to query leaderboard at this time."}
+	db.WithTx(func(tx *sql.Tx) error {
+		args := []interface{}{
+			start.Unix(), end.Unix(), int64(config.VotingWindow / time.Second)}
+		var where string
+		if groupID == 0 {
+			where = notBlockedClause("Accounts.id")
+			args = append(args, userID, userID)
+		} else {
+			var members []User
+			members, errors = contactGroupMembers(tx, userID, groupID)
+			if errors != nil {
+				return errors
+			}
+
+			args = append(args, userID)
+			for _, member := range members {
+				args = append(args, member.ID)
+			}
+			where = "Accounts.id IN (" + common.Placeholders(len(members)+1) + ")"
+		}
+		args = append(args, MaxLeaderboardEntries)
+
+		rows, err := tx.Query(
+			`SELECT Accounts.id, Accounts.display_name, COUNT(*) AS points
+			 FROM Votes
+			 INNER JOIN Turns ON Turns.id = Votes.turn_id
+			 INNER JOIN Accounts ON Accounts.id = Turns.account_id
+			 INNER JOIN Games ON Games.id = Votes.game_id
+			 INNER JOIN GamesCompletedAt
+			         ON GamesCompletedAt.id = Games.completed_at_id
+			 WHERE GamesCompletedAt.completed_at >= FROM_UNIXTIME(?)
+			   AND GamesCompletedAt.completed_at < FROM_UNIXTIME(?)
+			   AND GamesCompletedAt.completed_at <= NOW() - INTERVAL ? SECOND
+			   AND `+where+`
+			 GROUP BY Accounts.id, Accounts.display_name
+			 ORDER BY points DESC, Accounts.display_name ASC
+			 LIMIT ?`,
+			args...)
+		if err != nil {
+			log.Warnf("Unable to query leaderboard, %v.", err)
+			errors = &Errors{App: errMsg}
+			return err
+		}
+		defer rows.Close()
+
+		for i := 0; rows.Next(); i++ {
+			var entry LeaderboardEntry
+			err := rows.Scan(&entry.User.ID, &entry.User.DisplayName, &entry.Points)
+			if err != nil {
+				log.Warnf("Unable to scan row, %v.", err.Error())
+				continue
+			}
+
+			entry.Rank = i + 1
+			if n := len(leaderboard.Entries); n > 0 &&
+				leaderboard.Entries[n-1].Points == entry.Points {
+				entry.Rank = leaderboard.Entries[n-1].Rank
+			}
+			leaderboard.Entries = append(leaderboard.Entries, entry)
+		}
+
+		return nil
+	})
+
+	if errors != nil {
+		return nil, errors
+	}
+	return leaderboard, nil
+}
@@ -1,23 +1,53 @@
 package models
 
+import (
+	"github.com/GreatestGuys/pifuxelck-server-go/server/models/common"
+)
+
 // Message corresponds to the top level JSON object that is returned by all
 // end points.
 type Message struct {
-	Errors       *Errors      `json:"errors,omitempty"`
-	Game         *Game        `json:"game,omitempty"`
-	Games        []Game       `json:"games,omitempty"`
-	InboxEntries []InboxEntry `json:"inbox_entries,omitempty"`
-	InboxEntry   *InboxEntry  `json:"inbox_entry,omitempty"`
-	Meta         *Meta        `json:"meta,omitempty"`
-	NewGame      *NewGame     `json:"new_game,omitempty"`
-	Turn         *Turn        `json:"turn,omitempty"`
-	User         *User        `json:"user,omitempty"`
+	ActiveGames    []ActiveGame    `json:"active_games,omitempty"`
+	Comment        *Comment        `json:"comment,omitempty"`
+	Comments       []Comment       `json:"comments,omitempty"`
+	ContactGroup   *ContactGroup   `json:"contact_group,omitempty"`
+	ContactGroups  []ContactGroup  `json:"contact_groups,omitempty"`
+	CreatedGame    *CreatedGame    `json:"created_game,omitempty"`
+	DisplayNames   []string        `json:"display_names,omitempty"`
+	Errors         *Errors         `json:"errors,omitempty"`
+	FriendRequests []FriendRequest `json:"friend_requests,omitempty"`
+	Game           *Game           `json:"game,omitempty"`
+	Games          []Game          `json:"games,omitempty"`
+	InboxEntries   []InboxEntry    `json:"inbox_entries,omitempty"`
+	InboxEntry     *InboxEntry     `json:"inbox_entry,omitempty"`
+	Leaderboard    *Leaderboard    `json:"leaderboard,omitempty"`
+	Meta           *Meta           `json:"meta,omitempty"`
+	NewGame        *NewGame        `json:"new_game,omitempty"`
+	Prompt         *Prompt         `json:"prompt,omitempty"`
+	Rematch        *Rematch        `json:"rematch,omitempty"`
+	Schedule       *Schedule       `json:"schedule,omitempty"`
+	Schedules      []Schedule      `json:"schedules,omitempty"`
+	Settings       *Settings       `json:"settings,omitempty"`
+	Turn           *Turn           `json:"turn,omitempty"`
+	User           *User           `json:"user,omitempty"`
+	Users          []User          `json:"users,omitempty"`
 }
 
 // Errors is a union of all possible error types. It is a sub-field of the
 // Message type.
 type Errors struct {
-	App     []string      `json:"application,omitempty"`
-	User    *UserError    `json:"user,omitempty"`
-	NewGame *NewGameError `json:"new_game,omitempty"`
+	App          []string           `json:"application,omitempty"`
+	ContactGroup *ContactGroupError `json:"contact_group,omitempty"`
+	User         *UserError         `json:"user,omitempty"`
+
+	// Lookup contains the per name errors of a batch contact lookup keyed by the
+	// requested display name.
+	Lookup   map[string]*UserError `json:"lookup,omitempty"`
+	NewGame  *NewGameError         `json:"new_game,omitempty"`
+	Schedule *ScheduleError        `json:"schedule,omitempty"`
+	Settings *SettingsError        `json:"settings,omitempty"`
+}
+
+func (e Errors) Error() string {
+	return common.ModelErrorHelper(e)
 }
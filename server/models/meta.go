@@ -3,4 +3,11 @@ package models
 // Meta encodes meta data that does not correspond to any particular model.
 type Meta struct {
 	Auth string `json:"auth,omitempty"`
+
+	// NextCursor is an opaque value that can be passed back to a paginated
+	// endpoint to retrieve the next page of results.
+	NextCursor string `json:"next_cursor,omitempty"`
+
+	// HasMore is true if there are results beyond NextCursor.
+	HasMore bool `json:"has_more,omitempty"`
 }
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"database/sql"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+)
+
+// The identifiers of the available game modes, which are stored with each
+// game and selected by NewGame.Mode.
+const (
+	GameModeDefault = "default"
+	GameModeCircle  = "circle"
+)
+
+// GameMode decides the rules of a game: who takes which turn, which turns are
+// drawings, what happens when a turn is skipped and when a game is over.
+type GameMode interface {
+	// ID returns the identifier that is stored with each game of this mode.
+	ID() string
+
+	// Order decides the order in which the players of a new game, other than
+	// the creator, take their turns. The shuffler is the only source of
+	// randomness, and the history describes the creator's recent games.
+	Order(shuffler Shuffler, history *OrderHistory, creatorID int64, playerIDs []int64) []int64
+
+	// Chains arranges the players of a new game, the creator first, into the
+	// turn order of each game to create. The first player of each game writes
+	// its starting label. If more than one game is returned, they form a circle
+	// that completes together.
+	Chains(playerIDs []int64) [][]int64
+
+	// IsDrawing reports whether the turn at the given 0-based index of a game is
+	// a drawing turn.
+	IsDrawing(index int) bool
+
+	// FlipOnSkip reports whether the pending turns after a skipped or expired
+	// turn swap between drawing and label turns, so that the game keeps
+	// alternating.
+	FlipOnSkip() bool
+
+	// IsComplete reports whether a game is over given the number of completed
+	// turns and the total number of turns of all the games it completes with.
+	IsComplete(completed, total int) bool
+}
+
+// defaultMode is a single game in which the creator writes a label and every
+// other player alternates between drawing the previous label and labeling the
+// previous drawing.
+type defaultMode struct{}
+
+func (defaultMode) ID() string { return GameModeDefault }
+
+func (defaultMode) Order(shuffler Shuffler, history *OrderHistory, creatorID int64, playerIDs []int64) []int64 {
+	return fairOrder(shuffler, history, creatorID, playerIDs)
+}
+
+func (defaultMode) Chains(playerIDs []int64) [][]int64 {
+	return [][]int64{playerIDs}
+}
+
+func (defaultMode) IsDrawing(index int) bool { return index%2 == 1 }
+
+func (defaultMode) FlipOnSkip() bool { return true }
+
+func (defaultMode) IsComplete(completed, total int) bool {
+	return completed == total
+}
+
+// circleMode creates one game per player. Each player writes the starting label
+// of their own game and then takes one turn in every other game, so that all
+// of the games pass around the circle at the same time, as in the paper
+// version of the game.
+type circleMode struct {
+	defaultMode
+}
+
+func (circleMode) ID() string { return GameModeCircle }
+
+func (circleMode) Chains(playerIDs []int64) [][]int64 {
+	chains := make([][]int64, 0, len(playerIDs))
+	for i := range playerIDs {
+		chains = append(chains, append(
+			append([]int64{}, playerIDs[i:]...), playerIDs[:i]...))
+	}
+	return chains
+}
+
+var gameModes = map[string]GameMode{
+	GameModeDefault: defaultMode{},
+	GameModeCircle:  circleMode{},
+}
+
+// gameModeByID returns the game mode with the given identifier, and false if
+// there is no such mode. The empty identifier refers to the default mode.
+func gameModeByID(id string) (GameMode, bool) {
+	if id == "" {
+		return defaultMode{}, true
+	}
+	mode, ok := gameModes[id]
+	return mode, ok
+}
+
+// gameModeOf returns the mode of a game. Games with an unknown mode are played
+// with the default mode.
+func gameModeOf(tx *sql.Tx, gameID int64) (GameMode, error) {
+	var id string
+	row := tx.QueryRow("SELECT mode FROM Games WHERE id = ?", gameID)
+	if err := row.Scan(&id); err != nil {
+		log.Warnf("Unable to look up the mode of game %v, %v.", gameID, err)
+		return nil, err
+	}
+
+	mode, ok := gameModeByID(id)
+	if !ok {
+		log.Warnf("Game %v has unknown mode %#v.", gameID, id)
+		return defaultMode{}, nil
+	}
+	return mode, nil
+}
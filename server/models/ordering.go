@@ -0,0 +1,135 @@
+package models
+
+import (
+	"database/sql"
+	"math/rand"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+)
+
+const (
+	// orderHistoryGames is the number of the creator's most recent games that
+	// are considered when ordering the players of a new game.
+	orderHistoryGames = 20
+
+	// orderCandidates is the number of random orders that are scored when
+	// looking for the fairest one.
+	orderCandidates = 64
+
+	// firstDrawerWeight is how much more a repeated first drawer counts against
+	// an order than a repeated pair of adjacent players.
+	firstDrawerWeight = 2
+)
+
+// Shuffler is the source of randomness used to order the players of a new
+// game. It is satisfied by *rand.Rand, so a rand.Rand with a fixed seed can be
+// used to make orderings reproducible.
+type Shuffler interface {
+	Perm(n int) []int
+}
+
+// globalShuffler uses the top level functions of math/rand, which are safe for
+// concurrent use.
+type globalShuffler struct{}
+
+func (globalShuffler) Perm(n int) []int { return rand.Perm(n) }
+
+// orderShuffler is the Shuffler used by CreateGame.
+var orderShuffler Shuffler = globalShuffler{}
+
+// OrderHistory summarizes the turn order of a user's recent games.
+type OrderHistory struct {
+	// Follows counts how many times one player took a turn immediately after
+	// another, keyed by the pair of account IDs in turn order.
+	Follows map[[2]int64]int
+
+	// Firsts counts how many times each player took the first turn after the
+	// starting label.
+	Firsts map[int64]int
+}
+
+// recentOrderHistory returns the turn order history of the given user's most
+// recent games.
+func recentOrderHistory(tx *sql.Tx, userID int64) (*OrderHistory, error) {
+	rows, err := tx.Query(
+		`SELECT Turns.game_id, Turns.account_id
+		 FROM Turns
+		 INNER JOIN (
+		    SELECT DISTINCT game_id FROM Turns
+		    WHERE account_id = ?
+		    ORDER BY game_id DESC
+		    LIMIT ?
+		 ) AS Recent ON Recent.game_id = Turns.game_id
+		 ORDER BY Turns.game_id ASC, Turns.id ASC`,
+		userID, orderHistoryGames)
+	if err != nil {
+		log.Warnf("Unable to look up recent turn orders, %v.", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := &OrderHistory{
+		Follows: make(map[[2]int64]int),
+		Firsts:  make(map[int64]int),
+	}
+	var lastGameID, lastPlayerID int64
+	var index int
+	for rows.Next() {
+		var gameID, playerID int64
+		if err := rows.Scan(&gameID, &playerID); err != nil {
+			return nil, err
+		}
+
+		if gameID != lastGameID {
+			index = 0
+		} else {
+			history.Follows[[2]int64{lastPlayerID, playerID}]++
+		}
+		if index == 1 {
+			history.Firsts[playerID]++
+		}
+
+		lastGameID, lastPlayerID = gameID, playerID
+		index++
+	}
+	return history, nil
+}
+
+// fairOrder returns the order of the given players, who follow creatorID, that
+// least repeats the adjacent pairs and first drawers in the history. A number
+// of random orders are drawn from the shuffler and the first one with the
+// lowest score is returned, so ties are broken randomly.
+func fairOrder(shuffler Shuffler, history *OrderHistory, creatorID int64, playerIDs []int64) []int64 {
+	var best []int64
+	bestScore := -1
+	for i := 0; i < orderCandidates; i++ {
+		candidate := make([]int64, 0, len(playerIDs))
+		for _, v := range shuffler.Perm(len(playerIDs)) {
+			candidate = append(candidate, playerIDs[v])
+		}
+
+		score := orderScore(history, creatorID, candidate)
+		if bestScore < 0 || score < bestScore {
+			best, bestScore = candidate, score
+		}
+		if bestScore == 0 {
+			break
+		}
+	}
+	return best
+}
+
+// orderScore measures how much an order repeats the history. Lower is fairer.
+func orderScore(history *OrderHistory, creatorID int64, order []int64) int {
+	if len(order) == 0 {
+		return 0
+	}
+
+	score := firstDrawerWeight * history.Firsts[order[0]]
+	previous := creatorID
+	for _, playerID := range order {
+		score += history.Follows[[2]int64{previous, playerID}]
+		previous = playerID
+	}
+	return score
+}
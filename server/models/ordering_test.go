@@ -0,0 +1,97 @@
+package models
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestOrderScore(t *testing.T) {
+	history := &OrderHistory{
+		Follows: map[[2]int64]int{
+			{10, 1}: 1,
+			{1, 2}:  3,
+			{2, 3}:  1,
+		},
+		Firsts: map[int64]int{
+			1: 2,
+			3: 1,
+		},
+	}
+
+	tests := []struct {
+		name  string
+		order []int64
+		want  int
+	}{
+		{"empty", []int64{}, 0},
+		{"no repeats", []int64{2, 1, 3}, 0},
+		{"repeated first drawer", []int64{3}, firstDrawerWeight},
+		{"first drawer and creator pair", []int64{1}, firstDrawerWeight*2 + 1},
+		{"adjacent pairs", []int64{2, 3}, 1},
+		{"everything", []int64{1, 2, 3}, firstDrawerWeight*2 + 1 + 3 + 1},
+	}
+
+	for _, test := range tests {
+		got := orderScore(history, 10, test.order)
+		if got != test.want {
+			t.Errorf("%s: orderScore(%v) = %v, want %v",
+				test.name, test.order, got, test.want)
+		}
+	}
+}
+
+func TestFairOrderAvoidsRepeats(t *testing.T) {
+	// Player 1 always draws first, the creator 10 is always followed by 2, and
+	// 2 and 3 always sit next to each other. The only order that repeats none
+	// of these is 3, 1, 2.
+	history := &OrderHistory{
+		Follows: map[[2]int64]int{
+			{10, 2}: 1,
+			{2, 3}:  1,
+			{3, 2}:  1,
+		},
+		Firsts: map[int64]int{
+			1: 5,
+		},
+	}
+	want := []int64{3, 1, 2}
+
+	for seed := int64(1); seed <= 20; seed++ {
+		shuffler := rand.New(rand.NewSource(seed))
+		got := fairOrder(shuffler, history, 10, []int64{1, 2, 3})
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("seed %v: fairOrder = %v, want %v", seed, got, want)
+		}
+	}
+}
+
+func TestFairOrderBreaksTiesRandomly(t *testing.T) {
+	history := &OrderHistory{
+		Follows: map[[2]int64]int{},
+		Firsts:  map[int64]int{},
+	}
+	players := []int64{1, 2, 3, 4}
+
+	orders := make(map[[4]int64]bool)
+	for seed := int64(1); seed <= 20; seed++ {
+		shuffler := rand.New(rand.NewSource(seed))
+		got := fairOrder(shuffler, history, 10, players)
+		if len(got) != len(players) {
+			t.Fatalf("seed %v: fairOrder = %v, want %v players",
+				seed, got, len(players))
+		}
+		orders[[4]int64{got[0], got[1], got[2], got[3]}] = true
+	}
+
+	if len(orders) < 2 {
+		t.Errorf("fairOrder returned the same order for every seed, %v", orders)
+	}
+
+	// The same seed must give the same order.
+	first := fairOrder(rand.New(rand.NewSource(7)), history, 10, players)
+	second := fairOrder(rand.New(rand.NewSource(7)), history, 10, players)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("fairOrder with the same seed = %v and %v", first, second)
+	}
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+)
+
+// bcryptMaxLength is the number of bytes of a password that bcrypt considers,
+// any bytes beyond this are silently ignored.
+const bcryptMaxLength = 72
+
+// breachedPrefixLength is the number of hex characters of a SHA-1 hash that
+// name the range file containing the rest of the hash, as in the published
+// k-anonymity range files of breached password lists.
+const breachedPrefixLength = 5
+
+// minContainedNameLength is the number of characters a display name must have
+// before passwords containing it are rejected. Shorter names are common
+// substrings of unrelated passwords.
+const minContainedNameLength = 4
+
+// PasswordPolicy defines the rules that a password must satisfy in order to be
+// accepted when registering or changing a password.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+
+	// BreachedPasswordsDir is the path to a directory of k-anonymity range
+	// files of breached passwords. Each file is named by the first five upper
+	// case hex characters of a SHA-1 hash followed by ".txt", and contains the
+	// remaining 35 characters of each breached hash with that prefix, one per
+	// line, optionally followed by a colon and an occurrence count, which is
+	// ignored. A missing file means no breached hash has that prefix. Files are
+	// read on demand, one per check, so the full published corpus can be used.
+	// If empty, passwords are not screened.
+	BreachedPasswordsDir string
+}
+
+var defaultPasswordPolicy = PasswordPolicy{
+	MinLength: 8,
+	MaxLength: bcryptMaxLength,
+}
+
+// initPasswordPolicy clamps the limits of a policy to sane values and checks
+// that the breached password directory exists. A missing directory is fatal, as
+// silently running without screening is worse than not running at all.
+func initPasswordPolicy(p PasswordPolicy) PasswordPolicy {
+	if p.MinLength < 1 {
+		p.MinLength = 1
+	}
+	if p.MaxLength <= 0 || p.MaxLength > bcryptMaxLength {
+		p.MaxLength = bcryptMaxLength
+	}
+
+	if p.BreachedPasswordsDir == "" {
+		return p
+	}
+
+	info, err := os.Stat(p.BreachedPasswordsDir)
+	if err == nil && !info.IsDir() {
+		err = errors.New("not a directory")
+	}
+	if err != nil {
+		log.Fatalf("Unable to use breached passwords from %v, %v.",
+			p.BreachedPasswordsDir, err)
+	}
+	return p
+}
+
+// isBreached returns true if the password appears in the breached password
+// range files of the policy. Only the range file of the password's hash prefix
+// is read. A range file that cannot be read is logged and treated as empty.
+func (p PasswordPolicy) isBreached(password string) bool {
+	if p.BreachedPasswordsDir == "" {
+		return false
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:breachedPrefixLength], hash[breachedPrefixLength:]
+
+	f, err := os.Open(filepath.Join(p.BreachedPasswordsDir, prefix+".txt"))
+	if os.IsNotExist(err) {
+		return false
+	}
+	if err != nil {
+		log.Warnf("Unable to open breached password range %v, %v.", prefix, err)
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, ":"); i >= 0 {
+			line = line[:i]
+		}
+		if strings.ToUpper(line) == suffix {
+			return true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Warnf("Unable to read breached password range %v, %v.", prefix, err)
+	}
+	return false
+}
+
+// validate checks a password for the user with the given display name against
+// the policy and returns all of the rules that it violates.
+func (p PasswordPolicy) validate(password, displayName string) *UserError {
+	var errs []string
+	if utf8.RuneCountInString(password) < p.MinLength {
+		errs = append(errs,
+			"Password must be at least "+strconv.Itoa(p.MinLength)+" characters.")
+	}
+	if len(password) > p.MaxLength {
+		errs = append(errs,
+			"Password must be at most "+strconv.Itoa(p.MaxLength)+" bytes.")
+	}
+
+	name := strings.ToLower(displayName)
+	if utf8.RuneCountInString(name) >= minContainedNameLength &&
+		strings.Contains(strings.ToLower(password), name) {
+		errs = append(errs, "Password must not contain your display name.")
+	}
+
+	if p.isBreached(password) {
+		errs = append(errs,
+			"Password has appeared in a data breach, please choose another.")
+	}
+
+	if len(errs) > 0 {
+		return &UserError{Password: errs}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeBreachedRange writes the range file of each password's hash into dir.
+func writeBreachedRange(t *testing.T, dir string, passwords ...string) {
+	ranges := make(map[string][]string)
+	for _, password := range passwords {
+		sum := sha1.Sum([]byte(password))
+		hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+		prefix := hash[:breachedPrefixLength]
+		ranges[prefix] = append(ranges[prefix], hash[breachedPrefixLength:]+":42")
+	}
+	for prefix, lines := range ranges {
+		path := filepath.Join(dir, prefix+".txt")
+		err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\r\n")), 0600)
+		if err != nil {
+			t.Fatalf("Unable to write %v, %v", path, err)
+		}
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "breached")
+	if err != nil {
+		t.Fatalf("Unable to create a temporary directory, %v", err)
+	}
+	defer os.RemoveAll(dir)
+	writeBreachedRange(t, dir, "password123", "correct horse")
+
+	policy := PasswordPolicy{MinLength: 8, MaxLength: 20, BreachedPasswordsDir: dir}
+	tests := []struct {
+		name        string
+		password    string
+		displayName string
+		errs        int
+	}{
+		{"valid", "hunter2hunter2", "alice", 0},
+		{"too short", "hunter2", "alice", 1},
+		{"length counted in runes", "ééééééé", "alice", 1},
+		{"too long", strings.Repeat("a", 21), "alice", 1},
+		{"contains display name", "xxAlicexx", "alice", 1},
+		{"contains short display name", "xxjoxxxx", "Jo", 0},
+		{"contains three rune name", "xxbobxxx", "bob", 0},
+		{"contains four rune name", "xxbobbxxx", "Bobb", 1},
+		{"breached", "password123", "alice", 1},
+		{"breached with a different case", "Password123", "alice", 0},
+		{"breached phrase", "correct horse", "alice", 1},
+		{"short and contains name", "alice1", "alice", 2},
+	}
+
+	for _, test := range tests {
+		userErr := policy.validate(test.password, test.displayName)
+		got := 0
+		if userErr != nil {
+			got = len(userErr.Password)
+		}
+		if got != test.errs {
+			t.Errorf("%s: validate(%#v, %#v) = %v, want %v errors",
+				test.name, test.password, test.displayName, userErr, test.errs)
+		}
+	}
+}
+
+func TestPasswordPolicyWithoutBreachedPasswords(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, MaxLength: 72}
+	if userErr := policy.validate("password123", "alice"); userErr != nil {
+		t.Errorf("validate = %v, want no errors", userErr)
+	}
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/models/common"
+)
+
+// resolveNewGamePlayers resolves the entries of NewGame.Players, which may be
+// account IDs or display names, and the members of NewGame.GroupID into the
+// list of users that will take turns in the new game. Every problem with the
+// requested players is reported at once in NewGameError.Players.
+func resolveNewGamePlayers(tx *sql.Tx, userID int64, newGame NewGame) ([]User, *Errors) {
+	genericError := &Errors{App: []string{"Unable to create a new game at this time."}}
+
+	blocked, blockedBy, err := blockedAccounts(tx, userID)
+	if err != nil {
+		return nil, genericError
+	}
+
+	byID, byName, err := lookupPlayers(tx, newGame.Players)
+	if err != nil {
+		return nil, genericError
+	}
+
+	var errs []string
+	players := make([]User, 0, len(newGame.Players))
+	seen := make(map[int64]bool)
+	for _, entry := range newGame.Players {
+		// Entries that look like IDs are treated as IDs first so that existing
+		// clients keep working, but fall back to display names so that a player
+		// whose name is a number can still be found.
+		user, ok := User{}, false
+		if id, err := strconv.ParseInt(entry, 10, 64); err == nil {
+			user, ok = byID[id]
+		}
+		if !ok {
+			user, ok = byName[strings.ToLower(normalizeDisplayName(entry))]
+		}
+
+		switch {
+		case !ok:
+			errs = append(errs, "No such player "+entry+".")
+		case user.ID == userID:
+			errs = append(errs, "You cannot add yourself to a game.")
+		case seen[user.ID]:
+			errs = append(errs, "Player "+user.DisplayName+" is listed more than once.")
+		case blocked[user.ID]:
+			errs = append(errs, "You have blocked player "+user.DisplayName+".")
+		case blockedBy[user.ID]:
+			errs = append(errs,
+				"Player "+user.DisplayName+" is not available to play with you.")
+		default:
+			players = append(players, user)
+		}
+
+		if ok {
+			seen[user.ID] = true
+		}
+	}
+
+	friendsOnly, err := friendsOnlyPlayers(tx, userID, players)
+	if err != nil {
+		return nil, genericError
+	}
+	for _, player := range players {
+		if friendsOnly[player.ID] {
+			errs = append(errs, "Player "+player.DisplayName+" only plays with friends.")
+		}
+	}
+
+	if newGame.GroupID != 0 {
+		members, errors := contactGroupMembers(tx, userID, newGame.GroupID)
+		if errors != nil {
+			return nil, &Errors{NewGame: &NewGameError{
+				Players: []string{"No such group."},
+			}}
+		}
+
+		memberFriendsOnly, err := friendsOnlyPlayers(tx, userID, members)
+		if err != nil {
+			return nil, genericError
+		}
+
+		// Group members are silently de-duplicated, and members that are blocked
+		// in either direction, or that only play with friends, are left out
+		// rather than preventing the whole group from being used.
+		for _, member := range members {
+			if member.ID == userID || seen[member.ID] ||
+				blocked[member.ID] || blockedBy[member.ID] ||
+				memberFriendsOnly[member.ID] {
+				continue
+			}
+			seen[member.ID] = true
+			players = append(players, member)
+		}
+		log.Debugf("Expanded contact group %v into %v players.",
+			newGame.GroupID, len(players))
+	}
+
+	if len(errs) == 0 && len(players) == 0 {
+		errs = append(errs, "At least one other player is required.")
+	}
+
+	if len(errs) > 0 {
+		log.Debugf("Failed to create game due to invalid players, %v.", errs)
+		return nil, &Errors{NewGame: &NewGameError{Players: errs}}
+	}
+	return players, nil
+}
+
+// lookupPlayers resolves a list of account IDs and display names with a single
+// query. The returned maps are keyed by ID and by lower cased display name.
+func lookupPlayers(tx *sql.Tx, entries []string) (map[int64]User, map[string]User, error) {
+	byID := make(map[int64]User)
+	byName := make(map[string]User)
+	if len(entries) == 0 {
+		return byID, byName, nil
+	}
+
+	var ids []interface{}
+	names := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		if id, err := strconv.ParseInt(entry, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+		names = append(names, strings.ToLower(normalizeDisplayName(entry)))
+	}
+
+	where := "display_name IN (" + common.Placeholders(len(names)) + ")"
+	args := names
+	if len(ids) > 0 {
+		where = "id IN (" + common.Placeholders(len(ids)) + ") OR " + where
+		args = append(ids, names...)
+	}
+
+	rows, err := tx.Query("SELECT id, display_name FROM Accounts WHERE "+where, args...)
+	if err != nil {
+		log.Warnf("Unable to look up players, %v.", err)
+		return nil, nil, err
+	}
+
+	for _, user := range rowsToUsers(rows) {
+		byID[user.ID] = user
+		byName[strings.ToLower(user.DisplayName)] = user
+	}
+	return byID, byName, nil
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"database/sql"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/db"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+)
+
+// The difficulties that a prompt in the prompt library may have.
+const (
+	PromptDifficultyEasy   = "easy"
+	PromptDifficultyMedium = "medium"
+	PromptDifficultyHard   = "hard"
+)
+
+// Prompt is a starting label from the server's curated prompt library.
+type Prompt struct {
+	ID         int64  `json:"id,omitempty"`
+	Text       string `json:"text,omitempty"`
+	Category   string `json:"category,omitempty"`
+	Difficulty string `json:"difficulty,omitempty"`
+	Locale     string `json:"locale,omitempty"`
+}
+
+// PromptFilter restricts the prompts that may be picked from the library.
+// Empty fields match every prompt.
+type PromptFilter struct {
+	Category   string `json:"category,omitempty"`
+	Difficulty string `json:"difficulty,omitempty"`
+	Locale     string `json:"locale,omitempty"`
+}
+
+// randomPromptInTx picks a random enabled prompt matching the filter. If the
+// filter is invalid or matches no prompt, the returned messages say why. An
+// error is returned if the library could not be queried.
+func randomPromptInTx(tx *sql.Tx, filter PromptFilter) (*Prompt, []string, error) {
+	switch filter.Difficulty {
+	case "", PromptDifficultyEasy, PromptDifficultyMedium, PromptDifficultyHard:
+	default:
+		return nil, []string{"Invalid prompt difficulty."}, nil
+	}
+
+	prompt := &Prompt{}
+	row := tx.QueryRow(
+		`SELECT id, text, category, difficulty, locale
+		 FROM Prompts
+		 WHERE enabled = 1
+		   AND (? = '' OR category = ?)
+		   AND (? = '' OR difficulty = ?)
+		   AND (? = '' OR locale = ?)
+		 ORDER BY RAND()
+		 LIMIT 1`,
+		filter.Category, filter.Category,
+		filter.Difficulty, filter.Difficulty,
+		filter.Locale, filter.Locale)
+	err := row.Scan(
+		&prompt.ID, &prompt.Text, &prompt.Category, &prompt.Difficulty,
+		&prompt.Locale)
+	if err == sql.ErrNoRows {
+		log.Debugf("No prompts match %+v.", filter)
+		return nil, []string{"No prompts match your request."}, nil
+	}
+	if err != nil {
+		log.Warnf("Unable to pick a prompt, %v.", err)
+		return nil, nil, err
+	}
+	return prompt, nil, nil
+}
+
+// RandomPrompt returns a random prompt from the library matching the filter.
+func RandomPrompt(filter PromptFilter) (*Prompt, *Errors) {
+	var prompt *Prompt
+	var errors *Errors
+	db.WithTx(func(tx *sql.Tx) error {
+		var problems []string
+		var err error
+		prompt, problems, err = randomPromptInTx(tx, filter)
+		if err != nil {
+			errors = &Errors{App: []string{"Unable to pick a prompt at this time."}}
+			return err
+		}
+		if problems != nil {
+			errors = &Errors{App: problems}
+			return errors
+		}
+		return nil
+	})
+
+	return prompt, errors
+}
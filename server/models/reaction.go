@@ -0,0 +1,446 @@
+package models
+
+import (
+	"database/sql"
+	"sort"
+	"strconv"
+	"unicode"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/db"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/models/common"
+)
+
+const maxEmojiLength = 8
+
+// Reaction is the aggregated count of a single emoji reaction. Mine is true if
+// the requesting user is one of the reactors.
+type Reaction struct {
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+	Mine  bool   `json:"mine,omitempty"`
+}
+
+// isEmojiModifierRune returns true for the runes that may follow the base of
+// an emoji to modify it or join it to the next one.
+func isEmojiModifierRune(r rune) bool {
+	switch {
+	case r >= 0x1F3FB && r <= 0x1F3FF: // Skin tone modifiers.
+		return true
+	case r == 0x200D: // Zero width joiner.
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F: // Variation selectors.
+		return true
+	case r == 0x20E3: // Combining enclosing keycap.
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // Tag sequences.
+		return true
+	}
+	return false
+}
+
+// isKeycapBase returns true for the runes that are only emoji when followed by
+// a variation selector or an enclosing keycap, such as the 1 of 1️⃣.
+func isKeycapBase(r rune) bool {
+	return (r >= '0' && r <= '9') || r == '#' || r == '*'
+}
+
+// validateEmoji checks that a reaction is a short sequence of emoji. Every
+// sequence starts with a symbol, or with a keycap base that is immediately
+// followed by U+FE0F or U+20E3, and modifiers may only follow a base.
+func validateEmoji(emoji string) *Errors {
+	invalid := &Errors{App: []string{"Invalid reaction."}}
+	runes := []rune(emoji)
+	if len(runes) == 0 || len(runes) > maxEmojiLength {
+		return invalid
+	}
+
+	hasBase := false
+	for i, r := range runes {
+		switch {
+		case isKeycapBase(r):
+			if i+1 >= len(runes) || (runes[i+1] != 0xFE0F && runes[i+1] != 0x20E3) {
+				return invalid
+			}
+			hasBase = true
+		case unicode.Is(unicode.So, r):
+			hasBase = true
+		case isEmojiModifierRune(r):
+			if !hasBase {
+				return invalid
+			}
+		default:
+			return invalid
+		}
+	}
+	return nil
+}
+
+// checkCompletedGameParticipant returns an error unless the game exists, has
+// completed and the given user took a turn in it.
+func checkCompletedGameParticipant(tx *sql.Tx, userID, gameID int64) *Errors {
+	var ok bool
+	row := tx.QueryRow(
+		`SELECT COUNT(*) > 0 FROM Games
+		 INNER JOIN Turns ON Turns.game_id = Games.id
+		 WHERE Games.id = ?
+		   AND Games.completed_at_id IS NOT NULL
+		   AND Turns.account_id = ?`,
+		gameID, userID)
+	err := row.Scan(&ok)
+	if err != nil || !ok {
+		log.Debugf("User %v is not a participant of completed game %v.", userID, gameID)
+		return &Errors{App: []string{"No such game."}}
+	}
+	return nil
+}
+
+// turnIDByIndex returns the ID of the turn with the given 1-based index in a
+// game.
+func turnIDByIndex(tx *sql.Tx, gameID int64, index int) (int64, *Errors) {
+	if index < 1 {
+		return 0, &Errors{App: []string{"No such turn."}}
+	}
+
+	var turnID int64
+	row := tx.QueryRow(
+		"SELECT id FROM Turns WHERE game_id = ? ORDER BY id ASC LIMIT 1 OFFSET ?",
+		gameID, index-1)
+	err := row.Scan(&turnID)
+	if err != nil {
+		return 0, &Errors{App: []string{"No such turn."}}
+	}
+	return turnID, nil
+}
+
+// AddReaction adds an emoji reaction by the given user to the turn with the
+// given 1-based index of a completed game in which the user participated.
+func AddReaction(userID, gameID int64, turnIndex int, emoji string) *Errors {
+	errors := validateEmoji(emoji)
+	if errors != nil {
+		return errors
+	}
+
+	db.WithTx(func(tx *sql.Tx) error {
+		errors = checkCompletedGameParticipant(tx, userID, gameID)
+		if errors != nil {
+			return errors
+		}
+
+		var turnID int64
+		turnID, errors = turnIDByIndex(tx, gameID, turnIndex)
+		if errors != nil {
+			return errors
+		}
+
+		log.Debugf("User %v reacting %#v to turn %v.", userID, emoji, turnID)
+		_, err := tx.Exec(
+			`INSERT IGNORE INTO Reactions (turn_id, account_id, emoji, created_at)
+			 VALUES (?, ?, ?, NOW())`,
+			turnID, userID, emoji)
+		if err != nil {
+			log.Warnf("Unable to add reaction, %v.", err)
+			errors = &Errors{App: []string{"Unable to react at this time."}}
+			return err
+		}
+
+		return nil
+	})
+
+	return errors
+}
+
+// RemoveReaction removes an emoji reaction by the given user from the turn
+// with the given 1-based index of a game.
+func RemoveReaction(userID, gameID int64, turnIndex int, emoji string) *Errors {
+	var errors *Errors
+	db.WithTx(func(tx *sql.Tx) error {
+		var turnID int64
+		turnID, errors = turnIDByIndex(tx, gameID, turnIndex)
+		if errors != nil {
+			return errors
+		}
+
+		log.Debugf("User %v removing reaction %#v from turn %v.", userID, emoji, turnID)
+		_, err := tx.Exec(
+			"DELETE FROM Reactions WHERE turn_id = ? AND account_id = ? AND emoji = ?",
+			turnID, userID, emoji)
+		if err != nil {
+			log.Warnf("Unable to remove reaction, %v.", err)
+			errors = &Errors{App: []string{"Unable to remove reaction at this time."}}
+			return err
+		}
+
+		return nil
+	})
+
+	return errors
+}
+
+// FavoriteGame marks a completed game in which the given user participated as
+// one of their favorites.
+func FavoriteGame(userID, gameID int64) *Errors {
+	var errors *Errors
+	db.WithTx(func(tx *sql.Tx) error {
+		errors = checkCompletedGameParticipant(tx, userID, gameID)
+		if errors != nil {
+			return errors
+		}
+
+		log.Debugf("User %v favoriting game %v.", userID, gameID)
+		_, err := tx.Exec(
+			`INSERT IGNORE INTO Favorites (account_id, game_id, created_at)
+			 VALUES (?, ?, NOW())`,
+			userID, gameID)
+		if err != nil {
+			log.Warnf("Unable to favorite game, %v.", err)
+			errors = &Errors{App: []string{"Unable to favorite game at this time."}}
+			return err
+		}
+
+		return nil
+	})
+
+	return errors
+}
+
+// UnfavoriteGame removes a game from the given user's favorites.
+func UnfavoriteGame(userID, gameID int64) *Errors {
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		log.Debugf("User %v unfavoriting game %v.", userID, gameID)
+		_, err := db.Exec(
+			"DELETE FROM Favorites WHERE account_id = ? AND game_id = ?",
+			userID, gameID)
+		if err != nil {
+			log.Warnf("Unable to unfavorite game, %v.", err)
+			errors = &Errors{App: []string{"Unable to unfavorite game at this time."}}
+		}
+	})
+
+	return errors
+}
+
+// FavoriteGames returns a page of the given user's favorite games, most
+// recently favorited first. The page starts after the favorite with ID
+// beforeID, or at the most recent favorite if beforeID is zero.
+func FavoriteGames(userID, beforeID int64, limit int) ([]Game, *Meta, *Errors) {
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	if limit > MaxHistoryLimit {
+		return nil, nil, &Errors{App: []string{
+			"At most " + strconv.Itoa(MaxHistoryLimit) + " games can be requested."}}
+	}
+	if beforeID <= 0 {
+		beforeID = 1<<63 - 1
+	}
+
+	var games []Game
+	meta := &Meta{}
+	var errors *Errors
+	errMsg := []string{"Unable to query favorites at this time."}
+	db.WithDB(func(db *sql.DB) {
+		// One more favorite than the limit is fetched to determine if there are
+		// more.
+		rows, err := db.Query(
+			`SELECT id, game_id FROM Favorites
+			 WHERE account_id = ? AND id < ?
+			 ORDER BY id DESC
+			 LIMIT ?`,
+			userID, beforeID, limit+1)
+		if err != nil {
+			log.Warnf("Unable to look up favorites, %v", err)
+			errors = &Errors{App: errMsg}
+			return
+		}
+
+		var favoriteIDs, gameIDs []int64
+		for rows.Next() {
+			var favoriteID, gameID int64
+			if err := rows.Scan(&favoriteID, &gameID); err != nil {
+				log.Warnf("Unable to scan row, %v.", err.Error())
+				continue
+			}
+			favoriteIDs = append(favoriteIDs, favoriteID)
+			gameIDs = append(gameIDs, gameID)
+		}
+		rows.Close()
+
+		if len(gameIDs) > limit {
+			meta.HasMore = true
+			favoriteIDs = favoriteIDs[:limit]
+			gameIDs = gameIDs[:limit]
+		}
+		if len(favoriteIDs) == 0 {
+			games = []Game{}
+			return
+		}
+		meta.NextCursor = strconv.FormatInt(favoriteIDs[len(favoriteIDs)-1], 10)
+
+		games, err = completedGamesByID(db, userID, gameIDs)
+		if err != nil {
+			log.Warnf("Unable to look up favorite games, %v", err)
+			errors = &Errors{App: errMsg}
+			return
+		}
+		attachReactions(db, userID, games)
+		attachVotes(db, userID, games)
+	})
+
+	if errors != nil {
+		return nil, nil, errors
+	}
+	return games, meta, nil
+}
+
+// completedGamesByID returns the completed games with the given IDs that the
+// user participated in, in the same order as the IDs.
+func completedGamesByID(db *sql.DB, userID int64, gameIDs []int64) ([]Game, error) {
+	args := make([]interface{}, 0, 2*len(gameIDs)+1)
+	args = append(args, userID)
+	for _, id := range gameIDs {
+		args = append(args, id)
+	}
+	for _, id := range gameIDs {
+		args = append(args, id)
+	}
+
+	placeholders := common.Placeholders(len(gameIDs))
+	rows, err := db.Query(
+		`SELECT
+		    Games.id,
+		    Games.completed_at_id,
+		    UNIX_TIMESTAMP(GamesCompletedAt.completed_at),
+		    Games.turn_duration,
+		    IFNULL(Games.circle_id, 0),
+		    Turns.id,
+		    Accounts.display_name,
+		    Turns.is_drawing,
+		    Turns.drawing,
+		    Turns.label
+		 From Turns as Turns
+		 INNER JOIN (
+		    SELECT id, completed_at_id, turn_duration, circle_id
+		    FROM Games as Games
+		    INNER JOIN (
+		        SELECT DISTINCT game_id FROM Turns AS T WHERE T.account_id = ?
+		    ) AS T ON T.game_id = Games.id
+		    WHERE Games.completed_at_id IS NOT NULL
+		      AND Games.id IN (`+placeholders+`)
+		 ) AS Games ON Turns.game_id = Games.id
+		 INNER JOIN (
+		    SELECT id, display_name
+		    FROM Accounts as Accounts
+		 ) AS Accounts ON Turns.account_id = Accounts.id
+		 INNER JOIN (
+		    SELECT id, completed_at FROM GamesCompletedAt as GamesCompletedAt
+		 ) AS GamesCompletedAt ON GamesCompletedAt.id = Games.completed_at_id
+		 GROUP BY Turns.id
+		 ORDER BY FIELD(Games.id, `+placeholders+`), Turns.id ASC`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return rowsToGames(rows), nil
+}
+
+// attachReactions fills in the reactions of each game and turn, and whether
+// each game is one of the given user's favorites. Failures are logged and
+// otherwise ignored, as reactions are not essential to viewing a game.
+func attachReactions(db *sql.DB, userID int64, games []Game) {
+	if len(games) == 0 {
+		return
+	}
+
+	turns := make(map[int64]*Turn)
+	gameIndex := make(map[int64]int)
+	gameIDs := make([]interface{}, 0, len(games))
+	for i := range games {
+		gameIndex[games[i].ID] = i
+		gameIDs = append(gameIDs, games[i].ID)
+		for _, turn := range games[i].Turns {
+			turns[turn.id] = turn
+		}
+	}
+
+	args := append([]interface{}{userID}, gameIDs...)
+	rows, err := db.Query(
+		`SELECT Turns.game_id, Turns.id, Reactions.emoji, COUNT(*),
+		        SUM(Reactions.account_id = ?) > 0
+		 FROM Reactions
+		 INNER JOIN Turns ON Turns.id = Reactions.turn_id
+		 WHERE Turns.game_id IN (`+common.Placeholders(len(gameIDs))+`)
+		 GROUP BY Turns.game_id, Turns.id, Reactions.emoji
+		 ORDER BY Turns.id ASC, Reactions.emoji ASC`,
+		args...)
+	if err != nil {
+		log.Warnf("Unable to look up reactions, %v.", err)
+		return
+	}
+
+	totals := make(map[int64]map[string]*Reaction)
+	for rows.Next() {
+		var gameID, turnID int64
+		var reaction Reaction
+		err := rows.Scan(&gameID, &turnID, &reaction.Emoji, &reaction.Count, &reaction.Mine)
+		if err != nil {
+			log.Warnf("Unable to scan row, %v.", err.Error())
+			continue
+		}
+
+		if turn := turns[turnID]; turn != nil {
+			turn.Reactions = append(turn.Reactions, reaction)
+		}
+
+		if totals[gameID] == nil {
+			totals[gameID] = make(map[string]*Reaction)
+		}
+		total := totals[gameID][reaction.Emoji]
+		if total == nil {
+			total = &Reaction{Emoji: reaction.Emoji}
+			totals[gameID][reaction.Emoji] = total
+		}
+		total.Count += reaction.Count
+		total.Mine = total.Mine || reaction.Mine
+	}
+	rows.Close()
+
+	for gameID, byEmoji := range totals {
+		game := &games[gameIndex[gameID]]
+		for _, reaction := range byEmoji {
+			game.Reactions = append(game.Reactions, *reaction)
+		}
+		sort.Sort(byCount(game.Reactions))
+	}
+
+	rows, err = db.Query(
+		`SELECT game_id FROM Favorites
+		 WHERE account_id = ? AND game_id IN (`+common.Placeholders(len(gameIDs))+`)`,
+		args...)
+	if err != nil {
+		log.Warnf("Unable to look up favorites, %v.", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var gameID int64
+		if err := rows.Scan(&gameID); err == nil {
+			games[gameIndex[gameID]].IsFavorite = true
+		}
+	}
+}
+
+// byCount sorts reactions from most to least popular.
+type byCount []Reaction
+
+func (r byCount) Len() int      { return len(r) }
+func (r byCount) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r byCount) Less(i, j int) bool {
+	if r[i].Count != r[j].Count {
+		return r[i].Count > r[j].Count
+	}
+	return r[i].Emoji < r[j].Emoji
+}
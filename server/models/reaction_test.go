@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestValidateEmoji(t *testing.T) {
+	tests := []struct {
+		emoji string
+		valid bool
+	}{
+		{"😀", true},
+		{"❤️", true},
+		{"👍🏽", true},
+		{"👩‍🚀", true},
+		{"👨‍👩‍👧", true},
+		{"🇨🇦", true},
+		{"1️⃣", true},
+		{"1⃣", true},
+		{"#️⃣", true},
+		{"*️⃣", true},
+		{"🏴\U000E0067\U000E0062\U000E0073\U000E0063\U000E0074\U000E007F", true},
+		{"😀😀", true},
+
+		{"", false},
+		{"a", false},
+		{"1", false},
+		{"#", false},
+		{"1a", false},
+		{"12", false},
+		{"‍", false},
+		{"️", false},
+		{"⃣", false},
+		{"🏽", false},
+		{"‍😀", false},
+		{"🏽😀", false},
+		{"^", false},
+		{"`", false},
+		{"¨", false},
+		{"😀 ", false},
+		{"😀😀😀😀😀😀😀😀😀", false},
+	}
+
+	for _, test := range tests {
+		errors := validateEmoji(test.emoji)
+		if valid := errors == nil; valid != test.valid {
+			t.Errorf("validateEmoji(%+q) valid = %v, want %v",
+				test.emoji, valid, test.valid)
+		}
+	}
+}
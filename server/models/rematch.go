@@ -0,0 +1,154 @@
+package models
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/db"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+)
+
+// Rematch is a request to start a new game with the players of a completed
+// game.
+type Rematch struct {
+	// Label is the requester's starting label for the new game.
+	Label string `json:"label,omitempty"`
+
+	// Rotate keeps the previous game's turn order but moves its first drawer to
+	// the end so that someone else draws first. Otherwise the order is shuffled
+	// as with any new game.
+	Rotate bool `json:"rotate,omitempty"`
+
+	// TurnDuration is the number of seconds each player has to take their turn.
+	// If zero, the previous game's turn duration is used.
+	TurnDuration int64 `json:"turn_duration,omitempty"`
+}
+
+// RematchGame creates a new game, started by the given user, with the
+// participants of a completed game they played in. Participants that have
+// blocked or been blocked by the requester, or that only play with friends and
+// are not friends of the requester, are left out. The new game is played in the
+// same mode as the completed one.
+func RematchGame(userID, gameID int64, rematch Rematch) (*CreatedGame, *Errors) {
+	newGame := NewGame{
+		Label:        rematch.Label,
+		TurnDuration: rematch.TurnDuration,
+		ordered:      rematch.Rotate,
+	}
+
+	var errors *Errors
+	errMsg := []string{"Unable to create a rematch at this time."}
+	db.WithTx(func(tx *sql.Tx) error {
+		errors = checkCompletedGameParticipant(tx, userID, gameID)
+		if errors != nil {
+			return errors
+		}
+
+		if newGame.TurnDuration == 0 {
+			row := tx.QueryRow("SELECT turn_duration FROM Games WHERE id = ?", gameID)
+			if err := row.Scan(&newGame.TurnDuration); err != nil {
+				log.Warnf("Unable to look up turn duration, %v.", err)
+				errors = &Errors{App: errMsg}
+				return err
+			}
+		}
+
+		mode, err := gameModeOf(tx, gameID)
+		if err != nil {
+			errors = &Errors{App: errMsg}
+			return err
+		}
+		newGame.Mode = mode.ID()
+
+		players, err := rematchPlayers(tx, userID, gameID, rematch.Rotate)
+		if err != nil {
+			errors = &Errors{App: errMsg}
+			return err
+		}
+
+		for _, player := range players {
+			newGame.Players = append(newGame.Players,
+				strconv.FormatInt(player.ID, 10))
+		}
+		return nil
+	})
+
+	if errors != nil {
+		return nil, errors
+	}
+
+	log.Debugf("User %v rematching game %v with %v players.",
+		userID, gameID, len(newGame.Players))
+	return CreateGame(userID, newGame)
+}
+
+// rematchPlayers returns the participants of a game other than the given user
+// in the order of their first turn, leaving out any that may not be added to a
+// game by the user. If rotate is set, the game's first drawer is moved to the
+// end, after the author of the starting label, so that someone else draws
+// first. Rotation happens before the user is left out, so the requester's own
+// place in the order does not decide who goes first.
+func rematchPlayers(tx *sql.Tx, userID, gameID int64, rotate bool) ([]User, error) {
+	rows, err := tx.Query(
+		`SELECT Accounts.id, Accounts.display_name
+		 FROM Turns
+		 INNER JOIN Accounts ON Accounts.id = Turns.account_id
+		 WHERE Turns.game_id = ?
+		 ORDER BY Turns.id ASC`,
+		gameID)
+	if err != nil {
+		log.Warnf("Unable to look up participants, %v.", err)
+		return nil, err
+	}
+	turns := rowsToUsers(rows)
+	if len(turns) == 0 {
+		return turns, nil
+	}
+
+	// The first turn is the starting label. The remaining turns give the order
+	// of the drawers and labelers, each counted once.
+	author := turns[0]
+	seen := map[int64]bool{author.ID: true}
+	var drawers []User
+	for _, player := range turns[1:] {
+		if !seen[player.ID] {
+			seen[player.ID] = true
+			drawers = append(drawers, player)
+		}
+	}
+
+	var candidates []User
+	if rotate && len(drawers) > 0 {
+		candidates = append(candidates, drawers[1:]...)
+		candidates = append(candidates, author, drawers[0])
+	} else {
+		candidates = append([]User{author}, drawers...)
+	}
+	return availablePlayers(tx, userID, candidates)
+}
+
+// availablePlayers returns the candidates, other than the given user, that the
+// user may add to a game. Candidates that have blocked or been blocked by the
+// user, or that only play with friends and are not friends of the user, are
+// left out.
+func availablePlayers(tx *sql.Tx, userID int64, candidates []User) ([]User, error) {
+	blocked, blockedBy, err := blockedAccounts(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	friendsOnly, err := friendsOnlyPlayers(tx, userID, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	players := make([]User, 0, len(candidates))
+	for _, player := range candidates {
+		if player.ID == userID || blocked[player.ID] || blockedBy[player.ID] ||
+			friendsOnly[player.ID] {
+			continue
+		}
+		players = append(players, player)
+	}
+	return players, nil
+}
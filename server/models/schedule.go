@@ -0,0 +1,513 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/db"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/models/common"
+)
+
+// Schedule is a recurring game between the owner and the members of one of
+// their contact groups. Each time the schedule runs a new game is created, and
+// the player who writes its starting label rotates through the group.
+type Schedule struct {
+	ID      int64 `json:"id,omitempty"`
+	GroupID int64 `json:"group_id,omitempty"`
+
+	// Cron is a five field cron expression, in UTC, of when games are created.
+	// When creating a schedule, Weekly may be given instead.
+	Cron   string  `json:"cron,omitempty"`
+	Weekly *Weekly `json:"weekly,omitempty"`
+
+	// Mode and TurnDuration are used for every game, see NewGame.
+	Mode         string `json:"mode,omitempty"`
+	TurnDuration int64  `json:"turn_duration,omitempty"`
+
+	Paused    bool  `json:"paused,omitempty"`
+	NextRunAt int64 `json:"next_run_at,omitempty"`
+	Runs      int   `json:"runs,omitempty"`
+
+	// LastError is why the most recent run did not create a game, in the same
+	// form as the errors of any other request. It is cleared by the next run
+	// that creates a game.
+	LastError *Errors `json:"last_error,omitempty"`
+
+	// games is the number of runs that created a game, which decides whose turn
+	// it is to start the next one.
+	games int
+}
+
+// Weekly is a schedule that runs once a week at the given time in UTC. Weekday
+// 0 is Sunday.
+type Weekly struct {
+	Weekday int `json:"weekday"`
+	Hour    int `json:"hour"`
+	Minute  int `json:"minute"`
+}
+
+type ScheduleError struct {
+	Cron         []string `json:"cron,omitempty"`
+	Group        []string `json:"group,omitempty"`
+	Mode         []string `json:"mode,omitempty"`
+	TurnDuration []string `json:"turn_duration,omitempty"`
+	Weekly       []string `json:"weekly,omitempty"`
+}
+
+func (e ScheduleError) Error() string {
+	return common.ModelErrorHelper(e)
+}
+
+const scheduleQuery = `
+	SELECT
+	    id,
+	    account_id,
+	    group_id,
+	    cron,
+	    mode,
+	    turn_duration,
+	    paused,
+	    UNIX_TIMESTAMP(next_run_at),
+	    runs,
+	    games,
+	    last_error
+	FROM Schedules`
+
+func rowToSchedule(row common.Scannable) (*Schedule, int64, error) {
+	var ownerID int64
+	var lastError sql.NullString
+	schedule := &Schedule{}
+	err := row.Scan(
+		&schedule.ID, &ownerID, &schedule.GroupID, &schedule.Cron,
+		&schedule.Mode, &schedule.TurnDuration, &schedule.Paused,
+		&schedule.NextRunAt, &schedule.Runs, &schedule.games, &lastError)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if lastError.Valid {
+		schedule.LastError = &Errors{}
+		err = json.Unmarshal([]byte(lastError.String), schedule.LastError)
+		if err != nil {
+			log.Warnf("Unable to parse last error of schedule %v, %v.",
+				schedule.ID, err)
+			schedule.LastError = &Errors{App: []string{lastError.String}}
+		}
+	}
+	return schedule, ownerID, nil
+}
+
+// validateSchedule checks a new schedule and returns its parsed cron
+// expression. A Weekly schedule is converted into its cron expression.
+func validateSchedule(schedule *Schedule) (*cronSchedule, *Errors) {
+	errs := &ScheduleError{}
+	if schedule.Weekly != nil {
+		w := schedule.Weekly
+		if schedule.Cron != "" {
+			errs.Cron = append(errs.Cron,
+				"A cron expression and a weekly time cannot both be given.")
+		}
+		if w.Weekday < 0 || w.Weekday > 7 {
+			errs.Weekly = append(errs.Weekly, "Weekday must be between 0 and 7.")
+		}
+		if w.Hour < 0 || w.Hour > 23 {
+			errs.Weekly = append(errs.Weekly, "Hour must be between 0 and 23.")
+		}
+		if w.Minute < 0 || w.Minute > 59 {
+			errs.Weekly = append(errs.Weekly, "Minute must be between 0 and 59.")
+		}
+		schedule.Cron = strconv.Itoa(w.Minute) + " " + strconv.Itoa(w.Hour) +
+			" * * " + strconv.Itoa(w.Weekday)
+		schedule.Weekly = nil
+	}
+
+	var cron *cronSchedule
+	if errs.Weekly == nil {
+		var err error
+		cron, err = parseCron(schedule.Cron)
+		if err != nil {
+			errs.Cron = append(errs.Cron, "Invalid schedule.")
+		} else if _, ok := cron.next(time.Now()); !ok {
+			errs.Cron = append(errs.Cron, "The schedule never runs.")
+		}
+	}
+
+	if schedule.GroupID == 0 {
+		errs.Group = append(errs.Group, "A group is required.")
+	}
+
+	mode, ok := gameModeByID(schedule.Mode)
+	if ok {
+		schedule.Mode = mode.ID()
+	} else {
+		errs.Mode = append(errs.Mode, "No such game mode.")
+	}
+
+	if _, errors := newGameTurnDuration(NewGame{TurnDuration: schedule.TurnDuration}); errors != nil {
+		errs.TurnDuration = errors.NewGame.TurnDuration
+	}
+
+	if errs.Cron != nil || errs.Group != nil || errs.Mode != nil ||
+		errs.TurnDuration != nil || errs.Weekly != nil {
+		return nil, &Errors{Schedule: errs}
+	}
+	return cron, nil
+}
+
+// Schedules returns the schedules owned by the given user.
+func Schedules(userID int64) ([]Schedule, *Errors) {
+	var schedules []Schedule
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		rows, err := db.Query(
+			scheduleQuery+` WHERE account_id = ? ORDER BY id ASC`, userID)
+		if err != nil {
+			log.Warnf("Unable to query schedules, %v.", err)
+			errors = &Errors{App: []string{"Unable to query schedules at this time."}}
+			return
+		}
+		defer rows.Close()
+
+		schedules = make([]Schedule, 0)
+		for rows.Next() {
+			schedule, _, err := rowToSchedule(rows)
+			if err != nil {
+				log.Warnf("Unable to scan row, %v.", err.Error())
+				continue
+			}
+			schedules = append(schedules, *schedule)
+		}
+	})
+
+	return schedules, errors
+}
+
+// CreateSchedule creates a schedule for one of the given user's contact
+// groups.
+func CreateSchedule(userID int64, schedule Schedule) (*Schedule, *Errors) {
+	cron, errors := validateSchedule(&schedule)
+	if errors != nil {
+		return nil, errors
+	}
+	next, _ := cron.next(time.Now())
+
+	var created *Schedule
+	errMsg := []string{"Unable to create schedule at this time."}
+	db.WithTx(func(tx *sql.Tx) error {
+		if checkContactGroupOwner(tx, userID, schedule.GroupID) != nil {
+			errors = &Errors{Schedule: &ScheduleError{Group: []string{"No such group."}}}
+			return errors
+		}
+
+		log.Debugf("User %v scheduling %#v games for group %v.",
+			userID, schedule.Cron, schedule.GroupID)
+		res, err := tx.Exec(
+			`INSERT INTO Schedules
+			 (account_id, group_id, cron, mode, turn_duration, paused, next_run_at, runs, games)
+			 VALUES (?, ?, ?, ?, ?, 0, FROM_UNIXTIME(?), 0, 0)`,
+			userID, schedule.GroupID, schedule.Cron, schedule.Mode,
+			schedule.TurnDuration, next.Unix())
+		if err != nil {
+			log.Warnf("Unable to insert schedule, %v.", err)
+			errors = &Errors{App: errMsg}
+			return err
+		}
+
+		scheduleID, err := res.LastInsertId()
+		if err != nil {
+			errors = &Errors{App: errMsg}
+			return err
+		}
+
+		created, _, err = rowToSchedule(
+			tx.QueryRow(scheduleQuery+` WHERE id = ?`, scheduleID))
+		if err != nil {
+			log.Warnf("Unable to look up new schedule, %v.", err)
+			errors = &Errors{App: errMsg}
+			return err
+		}
+
+		return nil
+	})
+
+	if errors != nil {
+		return nil, errors
+	}
+
+	notifyScheduleChange()
+	return created, nil
+}
+
+// SetSchedulePaused pauses or resumes one of the given user's schedules. A
+// resumed schedule next runs at its first time after now, so runs that were
+// missed while paused are not made up.
+func SetSchedulePaused(userID, scheduleID int64, paused bool) *Errors {
+	var errors *Errors
+	errMsg := []string{"Unable to update schedule at this time."}
+	db.WithTx(func(tx *sql.Tx) error {
+		schedule, ownerID, err := rowToSchedule(
+			tx.QueryRow(scheduleQuery+` WHERE id = ? FOR UPDATE`, scheduleID))
+		if err != nil || ownerID != userID {
+			errors = &Errors{App: []string{"No such schedule."}}
+			return errors
+		}
+
+		next := time.Unix(schedule.NextRunAt, 0)
+		if !paused {
+			ok := false
+			if cron, err := parseCron(schedule.Cron); err == nil {
+				next, ok = cron.next(time.Now())
+			}
+			if !ok {
+				log.Warnf("Schedule %v can no longer run.", scheduleID)
+				errors = &Errors{Schedule: &ScheduleError{
+					Cron: []string{"This schedule can no longer run."},
+				}}
+				return errors
+			}
+		}
+
+		log.Debugf("Setting schedule %v paused to %v.", scheduleID, paused)
+		_, err = tx.Exec(
+			`UPDATE Schedules SET paused = ?, next_run_at = FROM_UNIXTIME(?)
+			 WHERE id = ?`,
+			paused, next.Unix(), scheduleID)
+		if err != nil {
+			log.Warnf("Unable to update schedule, %v.", err)
+			errors = &Errors{App: errMsg}
+			return err
+		}
+
+		return nil
+	})
+
+	if errors == nil && !paused {
+		notifyScheduleChange()
+	}
+	return errors
+}
+
+// DeleteSchedule deletes one of the given user's schedules. Games that it
+// already created are unaffected.
+func DeleteSchedule(userID, scheduleID int64) *Errors {
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		log.Debugf("User %v deleting schedule %v.", userID, scheduleID)
+		res, err := db.Exec(
+			"DELETE FROM Schedules WHERE id = ? AND account_id = ?",
+			scheduleID, userID)
+		if err != nil {
+			log.Warnf("Unable to delete schedule, %v.", err)
+			errors = &Errors{App: []string{"Unable to delete schedule at this time."}}
+			return
+		}
+
+		i, err := res.RowsAffected()
+		if i <= 0 || err != nil {
+			errors = &Errors{App: []string{"No such schedule."}}
+		}
+	})
+
+	return errors
+}
+
+// scheduleChanges receives a value whenever a schedule may have become due
+// sooner than before, see ScheduleChanges.
+var scheduleChanges = make(chan struct{}, 1)
+
+// ScheduleChanges returns a channel that receives a value whenever a schedule
+// is created or resumed, so that a caller waiting for NextScheduleRun can look
+// again. Changes made by other servers are not seen.
+func ScheduleChanges() <-chan struct{} {
+	return scheduleChanges
+}
+
+// notifyScheduleChange signals ScheduleChanges without blocking. A change that
+// is already pending covers this one.
+func notifyScheduleChange() {
+	select {
+	case scheduleChanges <- struct{}{}:
+	default:
+	}
+}
+
+// NextScheduleRun returns the earliest time at which a schedule that is not
+// paused is due, and false if there is no such schedule.
+func NextScheduleRun() (time.Time, bool, *Errors) {
+	var next sql.NullInt64
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		row := db.QueryRow(
+			"SELECT UNIX_TIMESTAMP(MIN(next_run_at)) FROM Schedules WHERE paused = 0")
+		if err := row.Scan(&next); err != nil {
+			log.Warnf("Unable to query the next schedule run, %v.", err)
+			errors = &Errors{App: []string{"Unable to query schedules."}}
+		}
+	})
+
+	if errors != nil || !next.Valid {
+		return time.Time{}, false, errors
+	}
+	return time.Unix(next.Int64, 0), true, nil
+}
+
+// RunScheduledGames creates a game for every schedule that is due. Each due
+// schedule is first claimed by advancing it to its next run, so that a
+// schedule is run once even if several servers call this at the same time.
+// This method should be called periodically.
+func RunScheduledGames() *Errors {
+	var schedules []Schedule
+	var owners []int64
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		rows, err := db.Query(
+			scheduleQuery + ` WHERE paused = 0 AND next_run_at <= NOW()`)
+		if err != nil {
+			log.Warnf("Unable to query due schedules, %v.", err)
+			errors = &Errors{App: []string{"Unable to run schedules."}}
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			schedule, ownerID, err := rowToSchedule(rows)
+			if err != nil {
+				log.Warnf("Unable to scan row, %v.", err.Error())
+				continue
+			}
+			schedules = append(schedules, *schedule)
+			owners = append(owners, ownerID)
+		}
+	})
+
+	if errors != nil {
+		return errors
+	}
+
+	for i, schedule := range schedules {
+		if claimSchedule(schedule) {
+			recordScheduleRun(schedule.ID, runSchedule(owners[i], schedule))
+		}
+	}
+	return nil
+}
+
+// claimSchedule advances a due schedule to its next run and returns true if
+// this call is the one that advanced it. A schedule that can no longer run is
+// paused.
+func claimSchedule(schedule Schedule) bool {
+	next, ok := time.Now(), false
+	if cron, err := parseCron(schedule.Cron); err == nil {
+		next, ok = cron.next(time.Now())
+	}
+	if !ok {
+		log.Warnf("Schedule %v can no longer run, pausing it.", schedule.ID)
+		next = time.Now()
+	}
+
+	claimed := false
+	db.WithDB(func(db *sql.DB) {
+		res, err := db.Exec(
+			`UPDATE Schedules
+			 SET next_run_at = FROM_UNIXTIME(?), runs = runs + 1, paused = ?
+			 WHERE id = ? AND runs = ? AND paused = 0`,
+			next.Unix(), !ok, schedule.ID, schedule.Runs)
+		if err != nil {
+			log.Warnf("Unable to claim schedule %v, %v.", schedule.ID, err)
+			return
+		}
+
+		i, err := res.RowsAffected()
+		claimed = err == nil && i == 1
+	})
+	return claimed
+}
+
+// runSchedule creates the game of a schedule's run. The starter rotates through
+// the owner and the group's members in order of account ID, and writes the
+// starting label from their inbox. Members that the starter may not play with
+// are left out of that run. The returned errors say why no game was created.
+func runSchedule(ownerID int64, schedule Schedule) *Errors {
+	var starterID int64
+	var players []string
+	var errors *Errors
+	err := db.WithTx(func(tx *sql.Tx) error {
+		members, lookupErrors := contactGroupMembers(tx, ownerID, schedule.GroupID)
+		if lookupErrors != nil {
+			errors = lookupErrors
+			return errors
+		}
+
+		members = append(members, User{ID: ownerID})
+		sort.Sort(usersByID(members))
+		starterID = members[schedule.games%len(members)].ID
+
+		others, err := availablePlayers(tx, starterID, members)
+		if err != nil {
+			return err
+		}
+		for _, player := range others {
+			players = append(players, strconv.FormatInt(player.ID, 10))
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warnf("Unable to look up the players of schedule %v, %v.",
+			schedule.ID, err)
+		if errors == nil {
+			errors = &Errors{App: []string{"Unable to create the scheduled game."}}
+		}
+		return errors
+	}
+
+	created, errors := CreateGame(starterID, NewGame{
+		Players:      players,
+		Mode:         schedule.Mode,
+		TurnDuration: schedule.TurnDuration,
+		pendingLabel: true,
+	})
+	if errors != nil {
+		log.Warnf("Unable to create the game of schedule %v, %v.",
+			schedule.ID, errors)
+		return errors
+	}
+
+	log.Infof("Schedule %v created game %v started by %v.",
+		schedule.ID, created.GameID, starterID)
+	return nil
+}
+
+// recordScheduleRun stores the outcome of a schedule's run. A run that created
+// a game passes the turn to start to the next player and clears the last
+// error. A run that did not records why, so that the owner can see it, and the
+// same player starts the next run.
+func recordScheduleRun(scheduleID int64, errors *Errors) {
+	db.WithDB(func(db *sql.DB) {
+		var err error
+		if errors == nil {
+			_, err = db.Exec(
+				`UPDATE Schedules SET games = games + 1, last_error = NULL
+				 WHERE id = ?`,
+				scheduleID)
+		} else {
+			_, err = db.Exec(
+				"UPDATE Schedules SET last_error = ? WHERE id = ?",
+				errors.Error(), scheduleID)
+		}
+		if err != nil {
+			log.Warnf("Unable to record the run of schedule %v, %v.",
+				scheduleID, err)
+		}
+	})
+}
+
+// usersByID sorts users by account ID.
+type usersByID []User
+
+func (u usersByID) Len() int           { return len(u) }
+func (u usersByID) Swap(i, j int)      { u[i], u[j] = u[j], u[i] }
+func (u usersByID) Less(i, j int) bool { return u[i].ID < u[j].ID }
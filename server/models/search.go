@@ -0,0 +1,298 @@
+package models
+
+import (
+	"database/sql"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/db"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+)
+
+const (
+	// searchPageSize is the number of results returned per page of a search.
+	searchPageSize = 20
+
+	// maxSearchResults is the total number of ranked results that can be paged
+	// through for a single query.
+	maxSearchResults = 100
+
+	// maxSearchCandidates bounds the number of accounts that are fetched from
+	// the database and ranked for a single query.
+	maxSearchCandidates = 500
+
+	// maxSearchTrigrams bounds the number of LIKE clauses used to find fuzzy
+	// candidates.
+	maxSearchTrigrams = 16
+
+	// minSearchSimilarity is the similarity below which a candidate that is not
+	// a prefix match is dropped from the results.
+	minSearchSimilarity = 0.4
+)
+
+type searchCandidate struct {
+	user        User
+	isContact   bool
+	sharedGames int
+	isPrefix    bool
+	score       float64
+}
+
+// SearchUsers returns the page of users whose display names best match the
+// query, starting at the position encoded by cursor. Prefix matches are
+// ranked first, followed by fuzzy matches that start with the same character
+// as the query, and within each the user's contacts and frequent co-players
+// are ranked higher. Users that have blocked or been blocked by the searching
+// user are excluded. The returned cursor is empty if there are no further
+// results.
+func SearchUsers(userID int64, query, cursor string) ([]User, string, *Errors) {
+	query = strings.ToLower(normalizeDisplayName(query))
+	if query == "" {
+		return nil, "", &Errors{App: []string{"A search query is required."}}
+	}
+
+	offset := 0
+	if cursor != "" {
+		var err error
+		offset, err = strconv.Atoi(cursor)
+		if err != nil || offset < 0 || offset > maxSearchResults {
+			return nil, "", &Errors{App: []string{"Invalid cursor."}}
+		}
+	}
+
+	candidates, errors := searchCandidates(userID, query)
+	if errors != nil {
+		return nil, "", errors
+	}
+
+	ranked := rankSearchCandidates(query, candidates)
+	if len(ranked) > maxSearchResults {
+		ranked = ranked[:maxSearchResults]
+	}
+
+	users := make([]User, 0, searchPageSize)
+	for i := offset; i < len(ranked) && i < offset+searchPageSize; i++ {
+		users = append(users, ranked[i].user)
+	}
+
+	next := ""
+	if offset+searchPageSize < len(ranked) {
+		next = strconv.Itoa(offset + searchPageSize)
+	}
+	return users, next, nil
+}
+
+// escapeLike escapes the wildcard characters of a string so that it can be
+// used as a literal within a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// searchCandidates returns up to maxSearchCandidates users whose display names
+// start with the query or share a trigram with it. Only names that start with
+// the query's first character are considered, so that the search is a range
+// scan of the display_name index rather than a scan of every account. Fuzzy
+// matches therefore need the first character right. The exact match and the
+// prefix matches are returned before the fuzzy ones, so the limit never cuts
+// them off.
+func searchCandidates(userID int64, query string) ([]searchCandidate, *Errors) {
+	first := escapeLike(string([]rune(query)[:1])) + "%"
+	prefix := escapeLike(query) + "%"
+	where := []string{"display_name LIKE ?"}
+	args := []interface{}{userID, userID, first, prefix}
+	for i, trigram := range trigrams(query) {
+		if i >= maxSearchTrigrams {
+			break
+		}
+		where = append(where, "display_name LIKE ?")
+		args = append(args, "%"+escapeLike(trigram)+"%")
+	}
+	args = append(args, userID, userID, userID, query, prefix, maxSearchCandidates)
+
+	var candidates []searchCandidate
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		log.Debugf("Searching for users matching %#v.", query)
+
+		// The candidates are limited before the contact and shared game counts
+		// are computed, so that those are only computed for the returned rows.
+		rows, err := db.Query(
+			`SELECT
+			    A.id,
+			    A.display_name,
+			    EXISTS (
+			        SELECT 1 FROM Contacts
+			        WHERE account_id = ? AND contact_id = A.id
+			    ),
+			    (
+			        SELECT COUNT(DISTINCT Mine.game_id)
+			        FROM Turns AS Mine
+			        INNER JOIN Turns AS Theirs ON Theirs.game_id = Mine.game_id
+			        WHERE Mine.account_id = ? AND Theirs.account_id = A.id
+			    )
+			 FROM (
+			    SELECT id, display_name
+			    FROM Accounts
+			    WHERE display_name LIKE ?
+			      AND (`+strings.Join(where, " OR ")+`)
+			      AND id != ?
+			      AND `+notBlockedClause("Accounts.id")+`
+			    ORDER BY
+			       display_name = ? DESC,
+			       display_name LIKE ? DESC,
+			       id ASC
+			    LIMIT ?
+			 ) AS A`,
+			args...)
+		if err != nil {
+			log.Warnf("Unable to search for users, %v.", err)
+			errors = &Errors{App: []string{"Unable to search at this time."}}
+			return
+		}
+		defer rows.Close()
+
+		candidates = make([]searchCandidate, 0)
+		for rows.Next() {
+			var c searchCandidate
+			err := rows.Scan(
+				&c.user.ID, &c.user.DisplayName, &c.isContact, &c.sharedGames)
+			if err != nil {
+				log.Warnf("Unable to scan row, %v.", err.Error())
+				continue
+			}
+			candidates = append(candidates, c)
+		}
+	})
+
+	return candidates, errors
+}
+
+// rankSearchCandidates drops candidates that are not similar enough to the
+// query and sorts the rest from best to worst match.
+func rankSearchCandidates(query string, candidates []searchCandidate) []searchCandidate {
+	ranked := make([]searchCandidate, 0, len(candidates))
+	for _, c := range candidates {
+		name := strings.ToLower(c.user.DisplayName)
+		c.isPrefix = strings.HasPrefix(name, query)
+
+		similarity := editSimilarity(query, name)
+		if t := trigramSimilarity(query, name); t > similarity {
+			similarity = t
+		}
+		if !c.isPrefix && similarity < minSearchSimilarity {
+			continue
+		}
+
+		c.score = similarity
+		if c.isContact {
+			c.score += 0.5
+		}
+		if c.sharedGames > 10 {
+			c.score += 0.5
+		} else {
+			c.score += 0.05 * float64(c.sharedGames)
+		}
+
+		ranked = append(ranked, c)
+	}
+
+	sort.Sort(byRank(ranked))
+	return ranked
+}
+
+// byRank sorts search candidates with prefix matches first, then by
+// descending score, then alphabetically.
+type byRank []searchCandidate
+
+func (r byRank) Len() int      { return len(r) }
+func (r byRank) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r byRank) Less(i, j int) bool {
+	a, b := r[i], r[j]
+	if a.isPrefix != b.isPrefix {
+		return a.isPrefix
+	}
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	return strings.ToLower(a.user.DisplayName) < strings.ToLower(b.user.DisplayName)
+}
+
+// editSimilarity returns the Levenshtein distance between two strings scaled
+// to a similarity between 0 and 1.
+func editSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j] + 1
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+			if prev[j-1]+cost < cur[j] {
+				cur[j] = prev[j-1] + cost
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
+
+// trigrams returns the distinct three character substrings of a string.
+func trigrams(s string) []string {
+	r := []rune(s)
+	seen := make(map[string]bool)
+	result := make([]string, 0, len(r))
+	for i := 0; i+3 <= len(r); i++ {
+		t := string(r[i : i+3])
+		if !seen[t] {
+			seen[t] = true
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// trigramSimilarity returns the Jaccard similarity of the trigrams of two
+// strings. The strings are padded so that short strings and word boundaries
+// still contribute trigrams.
+func trigramSimilarity(a, b string) float64 {
+	ta := trigrams("  " + a + " ")
+	tb := trigrams("  " + b + " ")
+
+	set := make(map[string]bool, len(ta))
+	for _, t := range ta {
+		set[t] = true
+	}
+
+	shared := 0
+	for _, t := range tb {
+		if set[t] {
+			shared++
+		}
+	}
+
+	union := len(ta) + len(tb) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
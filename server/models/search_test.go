@@ -0,0 +1,110 @@
+package models
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestEditSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"abc", "abc", 1},
+		{"abc", "", 0},
+		{"", "abc", 0},
+		{"abc", "xyz", 0},
+		{"kitten", "sitting", 1 - 3.0/7},
+		{"ali", "alice", 1 - 2.0/5},
+		{"añb", "anb", 1 - 1.0/3},
+	}
+
+	for _, test := range tests {
+		got := editSimilarity(test.a, test.b)
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("editSimilarity(%#v, %#v) = %v, want %v",
+				test.a, test.b, got, test.want)
+		}
+		if reverse := editSimilarity(test.b, test.a); reverse != got {
+			t.Errorf("editSimilarity(%#v, %#v) = %v, but reversed = %v",
+				test.a, test.b, got, reverse)
+		}
+	}
+}
+
+func TestTrigrams(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"", []string{}},
+		{"ab", []string{}},
+		{"abc", []string{"abc"}},
+		{"abcd", []string{"abc", "bcd"}},
+		{"aaaa", []string{"aaa"}},
+		{"éèêë", []string{"éèê", "èêë"}},
+	}
+
+	for _, test := range tests {
+		if got := trigrams(test.s); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("trigrams(%#v) = %#v, want %#v", test.s, got, test.want)
+		}
+	}
+}
+
+func TestTrigramSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"abc", "abc", 1},
+		{"abc", "xyz", 0},
+		// "  ali " and "  alice " share "  a", " al" and "ali" out of 7.
+		{"ali", "alice", 3.0 / 7},
+	}
+
+	for _, test := range tests {
+		got := trigramSimilarity(test.a, test.b)
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("trigramSimilarity(%#v, %#v) = %v, want %v",
+				test.a, test.b, got, test.want)
+		}
+		if reverse := trigramSimilarity(test.b, test.a); reverse != got {
+			t.Errorf("trigramSimilarity(%#v, %#v) = %v, but reversed = %v",
+				test.a, test.b, got, reverse)
+		}
+	}
+}
+
+func TestRankSearchCandidates(t *testing.T) {
+	candidate := func(name string, isContact bool, sharedGames int) searchCandidate {
+		return searchCandidate{
+			user:        User{DisplayName: name},
+			isContact:   isContact,
+			sharedGames: sharedGames,
+		}
+	}
+	candidates := []searchCandidate{
+		candidate("Kali", false, 0),
+		candidate("Bob", false, 0),
+		candidate("Alice", false, 0),
+		candidate("Alib", false, 0),
+		candidate("Alia", false, 0),
+		candidate("Alison", true, 0),
+		candidate("Xali", false, 20),
+	}
+
+	var got []string
+	for _, c := range rankSearchCandidates("ali", candidates) {
+		got = append(got, c.user.DisplayName)
+	}
+
+	// Prefix matches come first, ordered by score and then by name, followed by
+	// the fuzzy matches. Bob is not similar enough to be kept.
+	want := []string{"Alison", "Alia", "Alib", "Alice", "Xali", "Kali"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rankSearchCandidates = %v, want %v", got, want)
+	}
+}
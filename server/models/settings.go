@@ -0,0 +1,145 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/db"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/models/common"
+)
+
+// maxAwayDuration is the longest a player may set themselves away for.
+const maxAwayDuration = 365 * 24 * time.Hour
+
+// Settings contains the per account preferences of a player. Fields are
+// pointers so that an update only needs to include the settings that change.
+type Settings struct {
+	// FriendsOnly restricts the players that can include this player in a game
+	// to those that are accepted friends.
+	FriendsOnly *bool `json:"friends_only,omitempty"`
+
+	// AwayUntil is the time, in seconds since the epoch, until which the player
+	// is away. While away the player is left out of new games and their turns
+	// in existing games are skipped as soon as they come up. Zero means that
+	// the player is not away, and setting it to zero ends an absence early.
+	AwayUntil *int64 `json:"away_until,omitempty"`
+}
+
+type SettingsError struct {
+	AwayUntil []string `json:"away_until,omitempty"`
+}
+
+func (e SettingsError) Error() string {
+	return common.ModelErrorHelper(e)
+}
+
+// GetSettings returns the settings of the given user.
+func GetSettings(userID int64) (*Settings, *Errors) {
+	var settings *Settings
+	var errors *Errors
+	db.WithDB(func(db *sql.DB) {
+		log.Debugf("Querying settings of user %v.", userID)
+		row := db.QueryRow(
+			`SELECT friends_only, IF(away_until > NOW(), UNIX_TIMESTAMP(away_until), 0)
+			 FROM Accounts WHERE id = ?`,
+			userID)
+
+		var friendsOnly bool
+		var awayUntil int64
+		err := row.Scan(&friendsOnly, &awayUntil)
+		if err != nil {
+			log.Warnf("Unable to query settings, %v.", err)
+			errors = &Errors{App: []string{"Unable to query settings at this time."}}
+			return
+		}
+
+		settings = &Settings{FriendsOnly: &friendsOnly, AwayUntil: &awayUntil}
+	})
+
+	return settings, errors
+}
+
+// UpdateSettings updates every setting of the given user that is set in
+// settings and leaves the rest unchanged.
+func UpdateSettings(userID int64, settings Settings) *Errors {
+	if settings.AwayUntil != nil &&
+		*settings.AwayUntil > time.Now().Add(maxAwayDuration).Unix() {
+		log.Debugf("Failed to update settings due to away until %v.",
+			*settings.AwayUntil)
+		return &Errors{Settings: &SettingsError{
+			AwayUntil: []string{"You can be away for at most a year."},
+		}}
+	}
+
+	var errors *Errors
+	db.WithTx(func(tx *sql.Tx) error {
+		if settings.FriendsOnly != nil {
+			log.Debugf("Setting friends only of user %v to %v.",
+				userID, *settings.FriendsOnly)
+			_, err := tx.Exec(
+				"UPDATE Accounts SET friends_only = ? WHERE id = ?",
+				*settings.FriendsOnly, userID)
+			if err != nil {
+				log.Warnf("Unable to update settings, %v.", err)
+				errors = &Errors{App: []string{"Unable to update settings at this time."}}
+				return err
+			}
+		}
+
+		if settings.AwayUntil != nil {
+			// A time that has already passed clears the absence.
+			var awayUntil interface{}
+			if *settings.AwayUntil > time.Now().Unix() {
+				awayUntil = *settings.AwayUntil
+			}
+
+			log.Debugf("Setting away until of user %v to %v.", userID, awayUntil)
+			_, err := tx.Exec(
+				"UPDATE Accounts SET away_until = FROM_UNIXTIME(?) WHERE id = ?",
+				awayUntil, userID)
+			if err != nil {
+				log.Warnf("Unable to update settings, %v.", err)
+				errors = &Errors{App: []string{"Unable to update settings at this time."}}
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return errors
+}
+
+// awayPlayers returns the set of players that are currently away.
+func awayPlayers(tx *sql.Tx, players []User) (map[int64]bool, error) {
+	result := make(map[int64]bool)
+	if len(players) == 0 {
+		return result, nil
+	}
+
+	args := make([]interface{}, 0, len(players))
+	for _, player := range players {
+		args = append(args, player.ID)
+	}
+
+	rows, err := tx.Query(
+		`SELECT id FROM Accounts
+		 WHERE id IN (`+common.Placeholders(len(players))+`)
+		   AND away_until > NOW()`,
+		args...)
+	if err != nil {
+		log.Warnf("Unable to check away players, %v.", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		result[id] = true
+	}
+	return result, nil
+}
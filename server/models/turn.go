@@ -12,33 +12,104 @@ import (
 // Turn is struct that contains all the information of a single step in a
 // pifuxelck game.
 type Turn struct {
-	Player    string   `json:"player,omitempty"`
-	IsDrawing bool     `json:"is_drawing,omitempty"`
-	Drawing   *Drawing `json:"drawing,omitempty"`
-	Label     string   `json:"label,omitempty"`
+	Player    string     `json:"player,omitempty"`
+	IsDrawing bool       `json:"is_drawing,omitempty"`
+	Drawing   *Drawing   `json:"drawing,omitempty"`
+	Label     string     `json:"label,omitempty"`
+	Reactions []Reaction `json:"reactions,omitempty"`
+
+	// Votes is the number of votes for the turn, which is only revealed once
+	// voting has closed. Voted is true if the requesting user voted for it.
+	Votes int  `json:"votes,omitempty"`
+	Voted bool `json:"voted,omitempty"`
+
+	id int64
 }
 
 // InboxEntry is a struct that contains all the information that a user needs
-// in order to take a turn.
+// in order to take a turn. PreviousTurn is nil if the user is to write the
+// game's starting label.
 type InboxEntry struct {
-	GameID       string `json:"game_id,omitempty"`
-	PreviousTurn *Turn  `json:"previous_turn,omitempty"`
+	GameID         string `json:"game_id,omitempty"`
+	PreviousTurn   *Turn  `json:"previous_turn,omitempty"`
+	TurnDuration   int64  `json:"turn_duration,omitempty"`
+	NextExpiration int64  `json:"next_expiration,omitempty"`
+	TurnIndex      int    `json:"turn_index,omitempty"`
+	TotalTurns     int    `json:"total_turns,omitempty"`
+	Creator        string `json:"creator,omitempty"`
+	CreatedAt      int64  `json:"created_at,omitempty"`
 }
 
+// The orders in which inbox entries can be returned by GetInboxEntriesForUser.
+const (
+	InboxSortByGame       = "game"
+	InboxSortByExpiration = "expiration"
+)
+
+// inboxEntryQuery selects the columns scanned by rowToInboxEntry for every game
+// that is waiting on a turn. The turn index is 1-based and counts the label
+// that started the game. The previous turn is missing from games whose
+// starting label has not been written yet, as in a circle.
+const inboxEntryQuery = `
+	SELECT
+	    IFNULL(T.id, 0),
+	    G.id,
+	    IFNULL(T.drawing, ''),
+	    IFNULL(T.label, ''),
+	    IFNULL(T.is_drawing, 0),
+	    G.turn_duration,
+	    UNIX_TIMESTAMP(G.next_expiration),
+	    Progress.completed + 1,
+	    Progress.total,
+	    Creator.display_name,
+	    UNIX_TIMESTAMP(G.created_at)
+	FROM Games AS G
+	INNER JOIN (
+	  SELECT MIN(CT.id), CT.game_id, CT.account_id
+	  FROM Turns AS CT
+	  WHERE is_complete = 0
+	  GROUP BY CT.game_id
+	) AS CT ON CT.game_id = G.id
+	LEFT JOIN (
+	  SELECT MAX(PT.id) as previous_turn_id, PT.game_id
+	  FROM Turns AS PT
+	  WHERE is_complete = 1
+	  GROUP BY PT.game_id
+	) AS PT ON PT.game_id = G.id
+	LEFT JOIN Turns AS T ON T.id = PT.previous_turn_id
+	INNER JOIN (
+	  SELECT
+	      game_id,
+	      SUM(is_complete) AS completed,
+	      COUNT(*) AS total,
+	      MIN(id) AS first_turn_id
+	  FROM Turns
+	  GROUP BY game_id
+	) AS Progress ON Progress.game_id = G.id
+	INNER JOIN Turns AS FirstTurn ON FirstTurn.id = Progress.first_turn_id
+	INNER JOIN Accounts AS Creator ON Creator.id = FirstTurn.account_id`
+
 func rowToInboxEntry(row common.Scannable) *InboxEntry {
 	turn := &Turn{}
 	entry := &InboxEntry{}
 	entry.PreviousTurn = turn
 
-	var turnID string
+	var turnID int64
 	var drawingJson string
 	err := row.Scan(
-		&turnID, &entry.GameID, &drawingJson, &turn.Label, &turn.IsDrawing)
+		&turnID, &entry.GameID, &drawingJson, &turn.Label, &turn.IsDrawing,
+		&entry.TurnDuration, &entry.NextExpiration, &entry.TurnIndex,
+		&entry.TotalTurns, &entry.Creator, &entry.CreatedAt)
 	if err != nil {
 		log.Debugf("Unable to scan row, %v.", err.Error())
 		return nil
 	}
 
+	if turnID == 0 {
+		entry.PreviousTurn = nil
+		return entry
+	}
+
 	// Only attempt to unmarshal the drawing if it is a drawing turn.
 	// Otherwise the drawing will be an empty string which is not valid JSON.
 	if turn.IsDrawing {
@@ -61,21 +132,7 @@ func GetInboxEntryByGameId(userID, gameID int64) (*InboxEntry, *Errors) {
 	db.WithDB(func(db *sql.DB) {
 		log.Debugf("Querying for all available inbox entries for %v.", userID)
 		row := db.QueryRow(
-			`SELECT T.id, T.game_id, T.drawing, T.label, T.is_drawing
-			 FROM Turns AS T
-			 INNER JOIN (
-			   SELECT MIN(CT.id), CT.game_id, CT.account_id
-			   FROM Turns AS CT
-			   WHERE is_complete = 0
-			   GROUP BY CT.game_id
-			 ) AS CT ON CT.game_id = T.game_id
-			 INNER JOIN (
-			   SELECT MAX(PT.id) as previous_turn_id, PT.game_id
-			   FROM Turns AS PT
-			   WHERE is_complete = 1
-			   GROUP BY PT.game_id
-			 ) AS PT ON PT.previous_turn_id = T.id
-			 WHERE CT.account_id = ? AND CT.game_id = ?`,
+			inboxEntryQuery+` WHERE CT.account_id = ? AND CT.game_id = ?`,
 			userID, gameID)
 
 		entry = rowToInboxEntry(row)
@@ -89,30 +146,27 @@ func GetInboxEntryByGameId(userID, gameID int64) (*InboxEntry, *Errors) {
 
 // GetInboxEntriesForUser returns a list of all inbox entries that are
 // currently open for a given player. These inbox entries represent all the
-// turns that the user can currently take.
-func GetInboxEntriesForUser(userID int64) ([]InboxEntry, *Errors) {
+// turns that the user can currently take. The entries are ordered by game ID,
+// or by soonest expiration if sortBy is InboxSortByExpiration.
+func GetInboxEntriesForUser(userID int64, sortBy string) ([]InboxEntry, *Errors) {
 	var entries []InboxEntry
 	var errors *Errors
 
+	var order string
+	switch sortBy {
+	case "", InboxSortByGame:
+		order = "G.id ASC"
+	case InboxSortByExpiration:
+		order = "G.next_expiration ASC, G.id ASC"
+	default:
+		return nil, &Errors{App: []string{"Invalid sort order."}}
+	}
+
 	var generalError = []string{"Unable to query inbox at this time."}
 	db.WithDB(func(db *sql.DB) {
 		log.Debugf("Querying for all available inbox entries for %v.", userID)
 		rows, err := db.Query(
-			`SELECT T.id, T.game_id, T.drawing, T.label, T.is_drawing
-			 FROM Turns AS T
-			 INNER JOIN (
-			   SELECT MIN(CT.id), CT.game_id, CT.account_id
-			   FROM Turns AS CT
-			   WHERE is_complete = 0
-			   GROUP BY CT.game_id
-			 ) AS CT ON CT.game_id = T.game_id
-			 INNER JOIN (
-			   SELECT MAX(PT.id) as previous_turn_id, PT.game_id
-			   FROM Turns AS PT
-			   WHERE is_complete = 1
-			   GROUP BY PT.game_id
-			 ) AS PT ON PT.previous_turn_id = T.id
-			 WHERE CT.account_id = ?`,
+			inboxEntryQuery+` WHERE CT.account_id = ? ORDER BY `+order,
 			userID)
 		if err != nil {
 			log.Debugf("Querying failed, %v.", err.Error())
@@ -155,7 +209,7 @@ func UpdateDrawingTurn(userID, gameID int64, drawing *Drawing) *Errors {
 			 SET
 			    drawing = ?,
 			    is_complete = 1,
-			    Games.next_expiration = NOW() + INTERVAL 2 DAY
+			    Games.next_expiration = NOW() + INTERVAL Games.turn_duration SECOND
 			 WHERE Turns.game_id = Games.id
 			   AND Turns.account_id = ?
 			   AND Turns.game_id = ?
@@ -196,7 +250,7 @@ func UpdateLabelTurn(userID, gameID int64, label string) *Errors {
 			 SET
 			    Turns.label = ?,
 			    Turns.is_complete = 1,
-			    Games.next_expiration = NOW() + INTERVAL 2 DAY
+			    Games.next_expiration = NOW() + INTERVAL Games.turn_duration SECOND
 			 WHERE Turns.game_id = Games.id
 			   AND Turns.account_id = ?
 			   AND Turns.game_id = ?
@@ -222,3 +276,85 @@ func UpdateLabelTurn(userID, gameID int64, label string) *Errors {
 	})
 	return errors
 }
+
+// SkipTurn skips the given user's current turn in a game exactly as if it had
+// expired. Players may only skip a limited number of turns per day.
+func SkipTurn(userID, gameID int64) *Errors {
+	log.Debugf("User %v skipping turn in game %v.", userID, gameID)
+
+	if config.DailySkipAllowance <= 0 {
+		log.Debugf("Skipping turns is disabled.")
+		return &Errors{App: []string{"Skipping turns is disabled."}}
+	}
+
+	var errors *Errors
+	errMsg := []string{"Unable to skip your turn at this time."}
+	db.WithTx(func(tx *sql.Tx) error {
+		// Lock the user's account before anything else so that concurrent skips
+		// are counted one after another and cannot exceed the allowance.
+		var id int64
+		row := tx.QueryRow("SELECT id FROM Accounts WHERE id = ? FOR UPDATE", userID)
+		err := row.Scan(&id)
+		if err != nil {
+			log.Warnf("Unable to lock account %v, %v.", userID, err)
+			errors = &Errors{App: errMsg}
+			return err
+		}
+
+		var skips int
+		row = tx.QueryRow(
+			`SELECT COUNT(*) FROM Skips
+			 WHERE account_id = ? AND created_at > NOW() - INTERVAL 1 DAY`,
+			userID)
+		err = row.Scan(&skips)
+		if err != nil {
+			log.Warnf("Unable to count skips, %v.", err)
+			errors = &Errors{App: errMsg}
+			return err
+		}
+
+		if skips >= config.DailySkipAllowance {
+			log.Debugf("User %v has no skips remaining.", userID)
+			errors = &Errors{App: []string{
+				"You have used all of your skips for today."}}
+			return errors
+		}
+
+		var turnID int64
+		row = tx.QueryRow(
+			`SELECT Turns.id FROM Turns
+			 INNER JOIN Games ON Games.id = Turns.game_id
+			 WHERE Turns.game_id = ?
+			   AND Turns.account_id = ?
+			   AND Games.completed_at_id IS NULL
+			   AND Turns.id = (
+			        SELECT MIN(T.id) FROM Turns AS T
+			        WHERE T.is_complete = 0 AND T.game_id = ?)`,
+			gameID, userID, gameID)
+		err = row.Scan(&turnID)
+		if err != nil {
+			log.Debugf("User %v has no current turn in game %v.", userID, gameID)
+			errors = &Errors{App: []string{"It is not your turn in this game."}}
+			return err
+		}
+
+		err = removeTurnInTx(tx, gameID, turnID)
+		if err != nil {
+			errors = &Errors{App: errMsg}
+			return err
+		}
+
+		_, err = tx.Exec(
+			"INSERT INTO Skips (account_id, game_id, created_at) VALUES (?, ?, NOW())",
+			userID, gameID)
+		if err != nil {
+			log.Warnf("Unable to record skip, %v.", err)
+			errors = &Errors{App: errMsg}
+			return err
+		}
+
+		return nil
+	})
+
+	return errors
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql"
+	"strings"
 
 	"github.com/GreatestGuys/pifuxelck-server-go/server/db"
 	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
@@ -28,11 +29,12 @@ func (e UserError) Error() string {
 	return common.ModelErrorHelper(e)
 }
 
-func hashPassword(password string) ([]byte, *UserError) {
-	if len(password) < 8 {
-		return nil, &UserError{
-			Password: []string{"Password must be at least 8 characters."},
-		}
+// hashPassword validates a password for the user with the given display name
+// against the configured password policy and returns its bcrypt hash.
+func hashPassword(password, displayName string) ([]byte, *UserError) {
+	userErr := config.PasswordPolicy.validate(password, displayName)
+	if userErr != nil {
+		return nil, userErr
 	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -48,18 +50,24 @@ func hashPassword(password string) ([]byte, *UserError) {
 // given credentials. This call can fail if the display name is already
 // registered, or if the password is not sufficiently complex.
 func CreateUser(user User) (_ *User, userErr *UserError) {
-	if user.DisplayName == "" {
-		return nil, &UserError{DisplayName: []string{"Username must be non-empty."}}
+	user.DisplayName, userErr = validateDisplayName(user.DisplayName)
+	if userErr != nil {
+		return nil, userErr
 	}
 
 	var hash []byte
-	hash, userErr = hashPassword(user.Password)
+	hash, userErr = hashPassword(user.Password, user.DisplayName)
 	if userErr != nil {
 		return nil, userErr
 	}
 
 	db.WithTx(func(tx *sql.Tx) error {
 		log.Debugf("Request to register the new user %#v.", user.DisplayName)
+		userErr = checkDisplayNameAvailable(tx, 0, user.DisplayName)
+		if userErr != nil {
+			return userErr
+		}
+
 		res, err := tx.Exec(
 			"INSERT INTO Accounts (display_name, password_hash) VALUES (?, ?)",
 			user.DisplayName, hash)
@@ -92,7 +100,7 @@ func UserLookupByPassword(user User) (id int64, userErr *UserError) {
 		log.Debugf("Retrieving password hash for user %#v.", user.DisplayName)
 		row := tx.QueryRow(
 			"SELECT id, password_hash FROM Accounts WHERE display_name = ?",
-			user.DisplayName)
+			normalizeDisplayName(user.DisplayName))
 
 		var hash []byte
 		err := row.Scan(&id, &hash)
@@ -114,28 +122,107 @@ func UserLookupByPassword(user User) (id int64, userErr *UserError) {
 	return id, userErr
 }
 
-// UserSetPassword takes a User object and updates their password.
-func UserSetPassword(user User) (*User, *UserError) {
-	hash, userErr := hashPassword(user.Password)
-	if userErr != nil {
-		return nil, userErr
-	}
-
+// UserUpdate takes a User object and changes the display name and password of
+// the user with the matching ID to the given values. Either may be empty, in
+// which case it is left unchanged. Both are validated before either is written,
+// so a rejected password does not leave a rename behind. The previous display
+// name is recorded so that it continues to resolve to this user for a while
+// after the rename, unless only its case changed.
+func UserUpdate(user User) (_ *User, userErr *UserError) {
 	db.WithTx(func(tx *sql.Tx) error {
-		log.Debugf("Updating password in db of user %#v.", user.DisplayName)
-		_, err := tx.Exec(
-			"UPDATE Accounts SET password_hash = ? WHERE id = ?",
-			hash, user.ID)
-
+		var oldName string
+		row := tx.QueryRow(
+			"SELECT display_name FROM Accounts WHERE id = ? FOR UPDATE", user.ID)
+		err := row.Scan(&oldName)
 		if err != nil {
-			log.Debugf("Update failed, %v.", err.Error())
-			userErr = &UserError{Password: []string{"Unable to set password."}}
+			log.Debugf("Lookup failed, %v.", err.Error())
+			userErr = &UserError{DisplayName: []string{"No such user."}}
 			return err
 		}
 
+		// A display name that is unchanged, or that only changes case, is not
+		// validated again, so that accounts whose names predate the current rules
+		// can still change their password when the client sends the name along
+		// with it. Names are unique regardless of case, so a change of case is
+		// cosmetic and the account keeps the name.
+		name := oldName
+		var nameErr *UserError
+		normalized := normalizeDisplayName(user.DisplayName)
+		switch {
+		case user.DisplayName == "" || normalized == oldName:
+		case strings.EqualFold(normalized, oldName):
+			name = normalized
+		default:
+			name, nameErr = validateDisplayName(user.DisplayName)
+			if nameErr == nil {
+				nameErr = checkDisplayNameAvailable(tx, user.ID, name)
+			}
+			if nameErr != nil {
+				name = normalized
+			}
+		}
+
+		// The password policy is checked against the new display name rather
+		// than the one stored in the database.
+		var hash []byte
+		var passwordErr *UserError
+		if user.Password != "" {
+			hash, passwordErr = hashPassword(user.Password, name)
+		}
+
+		if nameErr != nil || passwordErr != nil {
+			userErr = &UserError{}
+			if nameErr != nil {
+				userErr.DisplayName = nameErr.DisplayName
+			}
+			if passwordErr != nil {
+				userErr.Password = passwordErr.Password
+			}
+			return userErr
+		}
+
+		if name != oldName {
+			log.Debugf("Renaming user %#v to %#v.", oldName, name)
+			_, err = tx.Exec(
+				"UPDATE Accounts SET display_name = ? WHERE id = ?", name, user.ID)
+			if err != nil {
+				log.Debugf("Update failed, %v.", err.Error())
+				userErr = &UserError{DisplayName: []string{"Unable to set display name."}}
+				return err
+			}
+
+			if !strings.EqualFold(name, oldName) {
+				_, err = tx.Exec(
+					`INSERT INTO DisplayNameHistory (account_id, display_name, changed_at)
+					 VALUES (?, ?, NOW())`,
+					user.ID, oldName)
+				if err != nil {
+					log.Warnf("Unable to record previous display name, %v.", err)
+					userErr = &UserError{DisplayName: []string{"Unable to set display name."}}
+					return err
+				}
+			}
+		}
+
+		if hash != nil {
+			log.Debugf("Updating password in db of user %#v.", name)
+			_, err = tx.Exec(
+				"UPDATE Accounts SET password_hash = ? WHERE id = ?", hash, user.ID)
+			if err != nil {
+				log.Debugf("Update failed, %v.", err.Error())
+				userErr = &UserError{Password: []string{"Unable to set password."}}
+				return err
+			}
+		}
+
+		user.DisplayName = name
 		return nil
 	})
 
+	if userErr != nil {
+		return nil, userErr
+	}
+
 	user.Password = ""
-	return &user, userErr
+	return &user, nil
 }
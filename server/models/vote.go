@@ -0,0 +1,177 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/db"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/models/common"
+)
+
+// checkVotingOpen returns an error unless the given user participated in the
+// game and the game completed less than config.VotingWindow ago.
+func checkVotingOpen(tx *sql.Tx, userID, gameID int64) *Errors {
+	errors := checkCompletedGameParticipant(tx, userID, gameID)
+	if errors != nil {
+		return errors
+	}
+
+	var open bool
+	row := tx.QueryRow(
+		`SELECT COUNT(*) > 0 FROM Games
+		 INNER JOIN GamesCompletedAt ON GamesCompletedAt.id = Games.completed_at_id
+		 WHERE Games.id = ?
+		   AND GamesCompletedAt.completed_at > NOW() - INTERVAL ? SECOND`,
+		gameID, int64(config.VotingWindow/time.Second))
+	err := row.Scan(&open)
+	if err != nil || !open {
+		log.Debugf("Voting on game %v is closed.", gameID)
+		return &Errors{App: []string{"Voting on this game has closed."}}
+	}
+	return nil
+}
+
+// CastVote records the given user's vote for the turn with the given 1-based
+// index as the best drawing or the best label of a game, depending on the kind
+// of turn. Each participant has one vote of each kind per game, and voting
+// again replaces their earlier vote. Players may not vote for their own turns
+// or for the label that started the game.
+func CastVote(userID, gameID int64, turnIndex int) *Errors {
+	var errors *Errors
+	errMsg := []string{"Unable to vote at this time."}
+	db.WithTx(func(tx *sql.Tx) error {
+		errors = checkVotingOpen(tx, userID, gameID)
+		if errors != nil {
+			return errors
+		}
+
+		if turnIndex == 1 {
+			errors = &Errors{App: []string{
+				"The label that started the game cannot be voted for."}}
+			return errors
+		}
+
+		var turnID int64
+		turnID, errors = turnIDByIndex(tx, gameID, turnIndex)
+		if errors != nil {
+			return errors
+		}
+
+		var authorID int64
+		var isDrawing bool
+		row := tx.QueryRow("SELECT account_id, is_drawing FROM Turns WHERE id = ?", turnID)
+		if err := row.Scan(&authorID, &isDrawing); err != nil {
+			log.Warnf("Unable to look up turn %v, %v.", turnID, err)
+			errors = &Errors{App: errMsg}
+			return err
+		}
+
+		if authorID == userID {
+			errors = &Errors{App: []string{"You cannot vote for your own turn."}}
+			return errors
+		}
+
+		log.Debugf("User %v voting for turn %v.", userID, turnID)
+		_, err := tx.Exec(
+			`REPLACE INTO Votes (game_id, account_id, is_drawing, turn_id, created_at)
+			 VALUES (?, ?, ?, ?, NOW())`,
+			gameID, userID, isDrawing, turnID)
+		if err != nil {
+			log.Warnf("Unable to record vote, %v.", err)
+			errors = &Errors{App: errMsg}
+			return err
+		}
+
+		return nil
+	})
+
+	return errors
+}
+
+// RemoveVote withdraws the given user's vote for the turn with the given
+// 1-based index of a game while voting is still open.
+func RemoveVote(userID, gameID int64, turnIndex int) *Errors {
+	var errors *Errors
+	db.WithTx(func(tx *sql.Tx) error {
+		errors = checkVotingOpen(tx, userID, gameID)
+		if errors != nil {
+			return errors
+		}
+
+		var turnID int64
+		turnID, errors = turnIDByIndex(tx, gameID, turnIndex)
+		if errors != nil {
+			return errors
+		}
+
+		log.Debugf("User %v removing vote for turn %v.", userID, turnID)
+		_, err := tx.Exec(
+			"DELETE FROM Votes WHERE game_id = ? AND account_id = ? AND turn_id = ?",
+			gameID, userID, turnID)
+		if err != nil {
+			log.Warnf("Unable to remove vote, %v.", err)
+			errors = &Errors{App: []string{"Unable to remove vote at this time."}}
+			return err
+		}
+
+		return nil
+	})
+
+	return errors
+}
+
+// attachVotes fills in when voting closes on each game, which turns the given
+// user voted for, and the vote counts of games whose voting has closed.
+// Failures are logged and otherwise ignored.
+func attachVotes(db *sql.DB, userID int64, games []Game) {
+	if len(games) == 0 {
+		return
+	}
+
+	now := time.Now().Unix()
+	window := int64(config.VotingWindow / time.Second)
+	turns := make(map[int64]*Turn)
+	closed := make(map[int64]bool)
+	args := []interface{}{userID}
+	for i := range games {
+		game := &games[i]
+		game.VotingEndsAt = game.CompletedAt + window
+		closed[game.ID] = now >= game.VotingEndsAt
+		args = append(args, game.ID)
+		for _, turn := range game.Turns {
+			turns[turn.id] = turn
+		}
+	}
+
+	rows, err := db.Query(
+		`SELECT game_id, turn_id, COUNT(*), SUM(account_id = ?) > 0
+		 FROM Votes
+		 WHERE game_id IN (`+common.Placeholders(len(games))+`)
+		 GROUP BY game_id, turn_id`,
+		args...)
+	if err != nil {
+		log.Warnf("Unable to look up votes, %v.", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var gameID, turnID int64
+		var votes int
+		var voted bool
+		if err := rows.Scan(&gameID, &turnID, &votes, &voted); err != nil {
+			log.Warnf("Unable to scan row, %v.", err.Error())
+			continue
+		}
+
+		turn := turns[turnID]
+		if turn == nil {
+			continue
+		}
+		turn.Voted = voted
+		if closed[gameID] {
+			turn.Votes = votes
+		}
+	}
+}
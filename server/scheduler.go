@@ -0,0 +1,48 @@
+package server
+
+import (
+	"time"
+
+	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/models"
+)
+
+// scheduleRetryInterval is how long the scheduler waits before trying again
+// when it is unable to query the schedules, or when a due schedule could not be
+// run.
+const scheduleRetryInterval = time.Minute
+
+// maxScheduleSleep bounds how long the scheduler sleeps, so that schedules
+// created or resumed through another server are eventually noticed.
+const maxScheduleSleep = 24 * time.Hour
+
+// runScheduler creates the games of due schedules forever. Between runs it
+// sleeps until the next schedule is due, or until a schedule changes, so that
+// the MySQL server is not kept awake when no schedule is due.
+func runScheduler() {
+	log.Infof("Starting game scheduler.")
+	for {
+		if errors := models.RunScheduledGames(); errors != nil {
+			log.Errorf("Unable to run scheduled games, %v.", errors)
+		}
+
+		wait := maxScheduleSleep
+		next, ok, errors := models.NextScheduleRun()
+		if errors != nil {
+			wait = scheduleRetryInterval
+		} else if ok {
+			wait = next.Sub(time.Now())
+			if wait <= 0 {
+				wait = scheduleRetryInterval
+			} else if wait > maxScheduleSleep {
+				wait = maxScheduleSleep
+			}
+		}
+
+		log.Debugf("Next checking schedules in %v.", wait)
+		select {
+		case <-time.After(wait):
+		case <-models.ScheduleChanges():
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/GreatestGuys/pifuxelck-server-go/server/db"
 	"github.com/GreatestGuys/pifuxelck-server-go/server/handlers"
 	"github.com/GreatestGuys/pifuxelck-server-go/server/log"
+	"github.com/GreatestGuys/pifuxelck-server-go/server/models"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -14,8 +15,9 @@ import (
 // Config defines all the options that can be configured for a running instance
 // of the pifuxelck server.
 type Config struct {
-	Port     int
-	DBConfig db.Config
+	Port        int
+	DBConfig    db.Config
+	ModelConfig models.Config
 }
 
 // Run takes a Config and runs the pifuxelck server indefinitely.
@@ -26,6 +28,9 @@ func Run(config Config) {
 	log.Infof("Listening on port %v.", config.Port)
 
 	db.Init(config.DBConfig)
+	models.Init(config.ModelConfig)
+
+	go runScheduler()
 
 	http.Handle("/", newRouter())
 	http.ListenAndServe(address, nil)
@@ -42,6 +47,9 @@ func newRouter() *mux.Router {
 	handlers.InstallAccountHandlers(s)
 	handlers.InstallContactHandlers(s)
 	handlers.InstallGameHandlers(s)
+	handlers.InstallLeaderboardHandlers(s)
+	handlers.InstallPromptHandlers(s)
+	handlers.InstallScheduleHandlers(s)
 
 	return r
 }